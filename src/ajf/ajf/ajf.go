@@ -0,0 +1,339 @@
+package ajf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Line is a line of ajf file
+type Line struct {
+	// Num is 1-origin line number in the parsed file. It is 0 for added lines.
+	Num  int
+	Text string
+}
+
+// Entry is an assignment `Key=Value` in a namelist group
+type Entry struct {
+	Key   string
+	Value Value
+	// Line is index of the line in Group.Lines
+	Line int
+
+	start int
+	end   int
+}
+
+// Group is a namelist group, e.g.
+//
+//	&CNTRL
+//	  Method='MP2'
+//	/
+//
+// Lines keep the text of the group body as is, so comments and ordering are written back unchanged.
+type Group struct {
+	// Name is group name without '&', e.g. "CNTRL"
+	Name string
+	// Leading is comments, blank lines and template placeholders before the group
+	Leading []Line
+	Header  Line
+	Lines   []Line
+	Footer  Line
+
+	entries []Entry
+}
+
+// File is ABINIT-MP input (ajf) file
+type File struct {
+	Groups []*Group
+	// Trailing is lines after the last group
+	Trailing []Line
+}
+
+// UnterminatedGroup error
+type UnterminatedGroup struct {
+	Name string
+	Line int
+}
+
+func (err *UnterminatedGroup) Error() string {
+	return fmt.Sprintf("line %d: group &%s is not terminated by '/'", err.Line, err.Name)
+}
+
+// Parse parses ajf file
+func Parse(reader io.Reader) (*File, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var file File
+	var leading []Line
+	var group *Group
+	num := 0
+	for scanner.Scan() {
+		num++
+		line := Line{Num: num, Text: scanner.Text()}
+		code := strings.TrimSpace(stripComment(line.Text))
+
+		if group == nil {
+			if strings.HasPrefix(code, "&") && len(code) > 1 {
+				group = &Group{Name: strings.ToUpper(strings.Fields(code[1:])[0]), Leading: leading, Header: line}
+				leading = nil
+			} else {
+				leading = append(leading, line)
+			}
+			continue
+		}
+
+		if code == "/" || strings.EqualFold(code, "&END") {
+			group.Footer = line
+			group.parseEntries()
+			file.Groups = append(file.Groups, group)
+			group = nil
+			continue
+		}
+		group.Lines = append(group.Lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if group != nil {
+		return nil, &UnterminatedGroup{Name: group.Name, Line: group.Header.Num}
+	}
+	file.Trailing = leading
+	return &file, nil
+}
+
+// stripComment removes comment starting with '!' outside of quotes
+func stripComment(text string) string {
+	return text[:commentStart(text)]
+}
+
+func commentStart(text string) int {
+	var quote rune
+	for i, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '!':
+			return i
+		}
+	}
+	return len(text)
+}
+
+// parseEntries finds `Key=Value` assignments, separated by newlines or commas
+func (g *Group) parseEntries() {
+	g.entries = nil
+	for i, line := range g.Lines {
+		g.entries = append(g.entries, parseLineEntries(line.Text, i)...)
+	}
+}
+
+func parseLineEntries(text string, index int) []Entry {
+	code := text[:commentStart(text)]
+	var entries []Entry
+	pos := 0
+	for pos < len(code) {
+		eq := strings.IndexByte(code[pos:], '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(code[pos : pos+eq])
+		if i := strings.LastIndexAny(key, " ,\t"); i >= 0 {
+			key = key[i+1:]
+		}
+		start := pos + eq + 1
+		end := valueEnd(code, start)
+		if key == "" {
+			pos = end
+			continue
+		}
+		raw := code[start:end]
+		trimmedStart := start + len(raw) - len(strings.TrimLeft(raw, " \t"))
+		trimmedEnd := start + len(strings.TrimRight(raw, " \t"))
+		if trimmedEnd < trimmedStart {
+			trimmedEnd = trimmedStart
+		}
+		entries = append(entries, Entry{
+			Key:   key,
+			Value: Value(code[trimmedStart:trimmedEnd]),
+			Line:  index,
+			start: trimmedStart,
+			end:   trimmedEnd,
+		})
+		pos = end
+	}
+	return entries
+}
+
+// valueEnd returns end of the value starting at start, i.e. position of ',' separating next assignment
+func valueEnd(code string, start int) int {
+	var quote byte
+	for i := start; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			// comma followed by `Key=` separates assignments, otherwise it is a part of the value
+			rest := code[i+1:]
+			if eq := strings.IndexByte(rest, '='); eq >= 0 && isKey(strings.TrimSpace(rest[:eq])) {
+				return i
+			}
+		}
+	}
+	return len(code)
+}
+
+func isKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if !(c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 0 && '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Group returns the first group of the name (case insensitive)
+func (f *File) Group(name string) *Group {
+	for _, g := range f.Groups {
+		if strings.EqualFold(g.Name, name) {
+			return g
+		}
+	}
+	return nil
+}
+
+// AddGroup appends an empty group
+func (f *File) AddGroup(name string) *Group {
+	g := &Group{
+		Name:   strings.ToUpper(name),
+		Header: Line{Text: "&" + strings.ToUpper(name)},
+		Footer: Line{Text: "/"},
+	}
+	f.Groups = append(f.Groups, g)
+	return g
+}
+
+// Entries returns assignments in the group
+func (g *Group) Entries() []Entry {
+	return g.entries
+}
+
+// Get returns value of the key (case insensitive). The last assignment wins.
+func (g *Group) Get(key string) (Value, bool) {
+	e := g.entry(key)
+	if e == nil {
+		return "", false
+	}
+	return e.Value, true
+}
+
+// LineOf returns line number of the key in the parsed file, or the header line if the key is absent
+func (g *Group) LineOf(key string) int {
+	e := g.entry(key)
+	if e == nil || g.Lines[e.Line].Num == 0 {
+		return g.Header.Num
+	}
+	return g.Lines[e.Line].Num
+}
+
+func (g *Group) entry(key string) *Entry {
+	for i := len(g.entries) - 1; i >= 0; i-- {
+		if strings.EqualFold(g.entries[i].Key, key) {
+			return &g.entries[i]
+		}
+	}
+	return nil
+}
+
+// Set replaces value of the key, keeping indentation and comments of the line.
+// New key is appended at the end of the group.
+func (g *Group) Set(key string, value Value) {
+	if e := g.entry(key); e != nil {
+		text := g.Lines[e.Line].Text
+		g.Lines[e.Line].Text = text[:e.start] + string(value) + text[e.end:]
+	} else {
+		g.Lines = append(g.Lines, Line{Text: fmt.Sprintf("  %s=%s", key, value)})
+	}
+	g.parseEntries()
+}
+
+// Delete removes assignments of the key. Lines which become empty are removed.
+func (g *Group) Delete(key string) {
+	for {
+		e := g.entry(key)
+		if e == nil {
+			break
+		}
+		text := g.Lines[e.Line].Text
+		keyStart := strings.LastIndex(text[:e.start], e.Key)
+		rest := strings.TrimLeft(text[e.end:], " \t")
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimLeft(rest[1:], " \t")
+		}
+		text = strings.TrimRight(strings.TrimRight(text[:keyStart], " \t"), ",") + rest
+		if strings.TrimSpace(text) == "" {
+			g.Lines = append(g.Lines[:e.Line], g.Lines[e.Line+1:]...)
+		} else {
+			g.Lines[e.Line].Text = text
+		}
+		g.parseEntries()
+	}
+}
+
+// SetLines replaces body of the group, e.g. data lines of &FRAGMENT
+func (g *Group) SetLines(texts []string) {
+	g.Lines = make([]Line, len(texts))
+	for i, t := range texts {
+		g.Lines[i] = Line{Text: t}
+	}
+	g.parseEntries()
+}
+
+// WriteTo writes ajf file
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	write := func(line Line) error {
+		m, err := bw.WriteString(line.Text + "\n")
+		n += int64(m)
+		return err
+	}
+	for _, g := range f.Groups {
+		for _, l := range g.Leading {
+			if err := write(l); err != nil {
+				return n, err
+			}
+		}
+		if err := write(g.Header); err != nil {
+			return n, err
+		}
+		for _, l := range g.Lines {
+			if err := write(l); err != nil {
+				return n, err
+			}
+		}
+		if err := write(g.Footer); err != nil {
+			return n, err
+		}
+	}
+	for _, l := range f.Trailing {
+		if err := write(l); err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
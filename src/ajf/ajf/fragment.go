@@ -0,0 +1,331 @@
+package ajf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bond is a detached bond between BDA (bond detached atom) and BAA (bond attached atom)
+type Bond struct {
+	BDA int
+	BAA int
+}
+
+// Fragment is &FRAGMENT group.
+//
+//	number of atoms of each fragment (10 per line)
+//	charge of each fragment (10 per line)
+//	number of bonds (BAAs) of each fragment (10 per line)
+//	atom indices of each fragment (10 per line, each fragment starts on a new line)
+//	BDA BAA pairs (a pair per line)
+type Fragment struct {
+	NumAtoms []int
+	Charges  []int
+	NumBonds []int
+	Atoms    [][]int
+	Bonds    []Bond
+
+	// Positions are line numbers of the values in the parsed file
+	Positions FragmentPositions
+}
+
+// FragmentPositions are line numbers of values of &FRAGMENT group
+type FragmentPositions struct {
+	NumAtoms []int
+	Charges  []int
+	NumBonds []int
+	// Atoms are line numbers of the first atom of each fragment
+	Atoms []int
+	Bonds []int
+}
+
+// FragmentError error
+type FragmentError struct {
+	Line    int
+	Message string
+}
+
+func (err *FragmentError) Error() string {
+	return fmt.Sprintf("line %d: &FRAGMENT: %s", err.Line, err.Message)
+}
+
+type fragmentToken struct {
+	value int
+	line  int
+	// first is true if the token is the first one of the line
+	first bool
+}
+
+type fragmentTokens struct {
+	tokens []fragmentToken
+	pos    int
+	// last is line number of the footer, used for unexpected end of the group
+	last int
+}
+
+func (ts *fragmentTokens) next(what string) (fragmentToken, error) {
+	if ts.pos >= len(ts.tokens) {
+		return fragmentToken{}, &FragmentError{Line: ts.last, Message: "unexpected end of group, expected " + what}
+	}
+	t := ts.tokens[ts.pos]
+	ts.pos++
+	return t, nil
+}
+
+func (ts *fragmentTokens) ints(n int, what string) ([]int, []int, error) {
+	values := make([]int, n)
+	lines := make([]int, n)
+	for i := range values {
+		t, err := ts.next(what)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = t.value
+		lines[i] = t.line
+	}
+	return values, lines, nil
+}
+
+// ParseFragment parses &FRAGMENT group of nf fragments
+func ParseFragment(g *Group, nf int) (*Fragment, error) {
+	ts := fragmentTokens{last: g.Footer.Num}
+	for _, line := range g.Lines {
+		for i, field := range strings.Fields(stripComment(line.Text)) {
+			v, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, &FragmentError{Line: line.Num, Message: fmt.Sprintf("invalid integer %q", field)}
+			}
+			ts.tokens = append(ts.tokens, fragmentToken{value: v, line: line.Num, first: i == 0})
+		}
+	}
+
+	var frag Fragment
+	var err error
+	if frag.NumAtoms, frag.Positions.NumAtoms, err = ts.ints(nf, "number of atoms"); err != nil {
+		return nil, err
+	}
+	if frag.Charges, frag.Positions.Charges, err = ts.ints(nf, "fragment charge"); err != nil {
+		return nil, err
+	}
+	if frag.NumBonds, frag.Positions.NumBonds, err = ts.ints(nf, "number of bonds"); err != nil {
+		return nil, err
+	}
+
+	frag.Atoms = make([][]int, nf)
+	frag.Positions.Atoms = make([]int, nf)
+	nbonds := 0
+	for i := 0; i < nf; i++ {
+		if frag.NumAtoms[i] < 0 {
+			return nil, &FragmentError{Line: frag.Positions.NumAtoms[i], Message: fmt.Sprintf("negative number of atoms of fragment %d", i+1)}
+		}
+		if frag.NumBonds[i] < 0 {
+			return nil, &FragmentError{Line: frag.Positions.NumBonds[i], Message: fmt.Sprintf("negative number of bonds of fragment %d", i+1)}
+		}
+		nbonds += frag.NumBonds[i]
+
+		atoms := make([]int, frag.NumAtoms[i])
+		for j := range atoms {
+			t, err := ts.next(fmt.Sprintf("atom of fragment %d", i+1))
+			if err != nil {
+				return nil, err
+			}
+			if j == 0 {
+				frag.Positions.Atoms[i] = t.line
+				if !t.first {
+					return nil, &FragmentError{Line: t.line, Message: fmt.Sprintf("atoms of fragment %d must start on a new line", i+1)}
+				}
+			}
+			atoms[j] = t.value
+		}
+		frag.Atoms[i] = atoms
+	}
+
+	frag.Bonds = make([]Bond, nbonds)
+	frag.Positions.Bonds = make([]int, nbonds)
+	for i := range frag.Bonds {
+		bda, err := ts.next("BDA")
+		if err != nil {
+			return nil, err
+		}
+		baa, err := ts.next("BAA")
+		if err != nil {
+			return nil, err
+		}
+		frag.Bonds[i] = Bond{BDA: bda.value, BAA: baa.value}
+		frag.Positions.Bonds[i] = bda.line
+	}
+
+	if ts.pos < len(ts.tokens) {
+		t := ts.tokens[ts.pos]
+		return nil, &FragmentError{Line: t.line, Message: fmt.Sprintf("%d extra values after bonds", len(ts.tokens)-ts.pos)}
+	}
+	return &frag, nil
+}
+
+// NF is number of fragments
+func (f *Fragment) NF() int {
+	return len(f.NumAtoms)
+}
+
+// FragmentOf returns 1-origin fragment index of each atom
+func (f *Fragment) FragmentOf() map[int]int {
+	result := make(map[int]int)
+	for i, atoms := range f.Atoms {
+		for _, a := range atoms {
+			result[a] = i + 1
+		}
+	}
+	return result
+}
+
+// Format formats the group body in the same layout as FormatAbinitMpFragment.
+// An empty block is written as an empty line.
+func (f *Fragment) Format() []string {
+	var lines []string
+	lines = append(lines, wrap(f.NumAtoms)...)
+	lines = append(lines, wrap(f.Charges)...)
+	lines = append(lines, wrap(f.NumBonds)...)
+	if len(f.Atoms) == 0 {
+		lines = append(lines, "")
+	}
+	for _, atoms := range f.Atoms {
+		lines = append(lines, wrap(atoms)...)
+	}
+	if len(f.Bonds) == 0 {
+		lines = append(lines, "")
+	}
+	for _, b := range f.Bonds {
+		lines = append(lines, fmt.Sprintf("%8d%8d", b.BDA, b.BAA))
+	}
+	return lines
+}
+
+// Section formats the whole group, i.e. ABINITMP_FRAGMENT of templates
+func (f *Fragment) Section() string {
+	return "&FRAGMENT\n" + strings.Join(f.Format(), "\n") + "\n/"
+}
+
+// wrap formats values 10 per line, as wrapForAbinitMp
+func wrap(values []int) []string {
+	if len(values) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	for i := 0; i < len(values); i += 10 {
+		end := i + 10
+		if end > len(values) {
+			end = len(values)
+		}
+		var sb strings.Builder
+		for _, v := range values[i:end] {
+			fmt.Fprintf(&sb, "%8d", v)
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+// Fragment parses &FRAGMENT group, using NF of &FMOCNTRL.
+// It returns nil if the group is absent.
+func (f *File) Fragment() (*Fragment, error) {
+	g := f.Group("FRAGMENT")
+	if g == nil {
+		return nil, nil
+	}
+	fmo, err := f.FmoCntrl()
+	if err != nil {
+		return nil, err
+	}
+	return ParseFragment(g, fmo.NF)
+}
+
+// SetFragment replaces &FRAGMENT group and NF of &FMOCNTRL
+func (f *File) SetFragment(frag *Fragment) {
+	g := f.Group("FRAGMENT")
+	if g == nil {
+		g = f.AddGroup("FRAGMENT")
+	}
+	g.SetLines(frag.Format())
+
+	fmo := f.Group("FMOCNTRL")
+	if fmo == nil {
+		fmo = f.AddGroup("FMOCNTRL")
+	}
+	fmo.Set("NF", Int(frag.NF()))
+}
+
+// UnknownAtom error
+type UnknownAtom struct {
+	Serial int
+}
+
+func (err *UnknownAtom) Error() string {
+	return fmt.Sprintf("atom %d is not in the atom list", err.Serial)
+}
+
+// NewFragment builds &FRAGMENT as FormatAbinitMpFragment does.
+// atoms are atom serials in the order of ReadGeom file, and fragments and bonds are given by the serials.
+// Fragment charges are the formal charges plus the number of BDAs minus the number of BAAs in the fragment.
+func NewFragment(atoms []int, fragments [][]int, formalCharges []int, bonds []Bond) (*Fragment, error) {
+	index := make(map[int]int, len(atoms))
+	for i, serial := range atoms {
+		index[serial] = i + 1
+	}
+	fragmentOf := make(map[int]int)
+	for i, serials := range fragments {
+		for _, serial := range serials {
+			fragmentOf[serial] = i + 1
+		}
+	}
+
+	nf := len(fragments)
+	frag := Fragment{
+		NumAtoms: make([]int, nf),
+		Charges:  make([]int, nf),
+		NumBonds: make([]int, nf),
+		Atoms:    make([][]int, nf),
+	}
+	for i, serials := range fragments {
+		indices := make([]int, len(serials))
+		for j, serial := range serials {
+			idx, ok := index[serial]
+			if !ok {
+				return nil, &UnknownAtom{Serial: serial}
+			}
+			indices[j] = idx
+		}
+		sort.Ints(indices)
+		frag.Atoms[i] = indices
+		frag.NumAtoms[i] = len(indices)
+		if i < len(formalCharges) {
+			frag.Charges[i] = formalCharges[i]
+		}
+	}
+
+	// bonds are ordered by fragments of BAAs
+	attached := make([][]Bond, nf)
+	for _, b := range bonds {
+		bda, ok := index[b.BDA]
+		if !ok {
+			return nil, &UnknownAtom{Serial: b.BDA}
+		}
+		baa, ok := index[b.BAA]
+		if !ok {
+			return nil, &UnknownAtom{Serial: b.BAA}
+		}
+		if i := fragmentOf[b.BDA]; i > 0 {
+			frag.Charges[i-1]++
+		}
+		if i := fragmentOf[b.BAA]; i > 0 {
+			frag.Charges[i-1]--
+			attached[i-1] = append(attached[i-1], Bond{BDA: bda, BAA: baa})
+		}
+	}
+	for i, bs := range attached {
+		frag.NumBonds[i] = len(bs)
+		frag.Bonds = append(frag.Bonds, bs...)
+	}
+	return &frag, nil
+}
@@ -0,0 +1,148 @@
+package ajf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Cntrl is &CNTRL group
+type Cntrl struct {
+	Method    string `ajf:"Method"`
+	Memory    int    `ajf:"Memory"`
+	ReadGeom  string `ajf:"ReadGeom"`
+	WriteGeom string `ajf:"WriteGeom"`
+	Charge    int    `ajf:"Charge"`
+	CpfVer    int    `ajf:"CpfVer"`
+	Nprint    int    `ajf:"Nprint"`
+}
+
+// FmoCntrl is &FMOCNTRL group
+type FmoCntrl struct {
+	FMO                string `ajf:"FMO"`
+	NBody              int    `ajf:"NBody"`
+	AutoFrag           string `ajf:"AutoFrag"`
+	NF                 int    `ajf:"NF"`
+	FragSizeResidue    int    `ajf:"FragSizeResidue"`
+	FragSizeNucleotide string `ajf:"FragSizeNucleotide"`
+	FragSizeAminoacid  string `ajf:"FragSizeAminoacid"`
+	LigandCharge       string `ajf:"LigandCharge"`
+	HybridFrag         string `ajf:"HybridFrag"`
+	HybridNf           int    `ajf:"HybridNf"`
+	HybridSort         string `ajf:"HybridSort"`
+	Rsolv              string `ajf:"Rsolv"`
+	NP                 int    `ajf:"NP"`
+}
+
+// Basis is &BASIS group
+type Basis struct {
+	BasisSet string `ajf:"BasisSet"`
+}
+
+// Analysis is &ANALYSIS group
+type Analysis struct {
+	PIEDA string `ajf:"PIEDA"`
+}
+
+// Pop is &POP group
+type Pop struct {
+	ESPFIT string `ajf:"ESPFIT"`
+	ESPTYP string `ajf:"ESPTYP"`
+}
+
+// DecodeError error
+type DecodeError struct {
+	Group string
+	Key   string
+	Line  int
+	Err   error
+}
+
+func (err *DecodeError) Error() string {
+	return fmt.Sprintf("line %d: &%s %s: %v", err.Line, err.Group, err.Key, err.Err)
+}
+
+// Decode sets fields tagged by `ajf:"Key"` of struct pointed by v.
+// Fields of keys absent in the group are left unchanged.
+func (g *Group) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("ajf")
+		if key == "" {
+			continue
+		}
+		e := g.entry(key)
+		if e == nil {
+			continue
+		}
+		field := rv.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(e.Value.String())
+		case reflect.Int:
+			n, err := e.Value.Int()
+			if err != nil {
+				return &DecodeError{Group: g.Name, Key: key, Line: g.Lines[e.Line].Num, Err: err}
+			}
+			field.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := e.Value.Float()
+			if err != nil {
+				return &DecodeError{Group: g.Name, Key: key, Line: g.Lines[e.Line].Num, Err: err}
+			}
+			field.SetFloat(f)
+		}
+	}
+	return nil
+}
+
+// Encode writes fields tagged by `ajf:"Key"` of struct v to the group.
+// Keys present in the group are updated, and absent keys are added only if the field is not zero.
+func (g *Group) Encode(v interface{}) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("ajf")
+		if key == "" {
+			continue
+		}
+		field := rv.Field(i)
+		if g.entry(key) == nil && field.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			g.Set(key, Quote(field.String()))
+		case reflect.Int:
+			g.Set(key, Int(int(field.Int())))
+		case reflect.Float64:
+			g.Set(key, Float(field.Float()))
+		}
+	}
+}
+
+// Cntrl decodes &CNTRL group. It returns zero value if the group is absent.
+func (f *File) Cntrl() (Cntrl, error) {
+	var v Cntrl
+	return v, f.decode("CNTRL", &v)
+}
+
+// FmoCntrl decodes &FMOCNTRL group. It returns zero value if the group is absent.
+func (f *File) FmoCntrl() (FmoCntrl, error) {
+	var v FmoCntrl
+	return v, f.decode("FMOCNTRL", &v)
+}
+
+// Basis decodes &BASIS group. It returns zero value if the group is absent.
+func (f *File) Basis() (Basis, error) {
+	var v Basis
+	return v, f.decode("BASIS", &v)
+}
+
+func (f *File) decode(name string, v interface{}) error {
+	g := f.Group(name)
+	if g == nil {
+		return nil
+	}
+	return g.Decode(v)
+}
@@ -0,0 +1,76 @@
+package ajf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Value is raw text of a namelist value, e.g. 'MP2', 3000 or 1.0d-5
+type Value string
+
+// ValueError error
+type ValueError struct {
+	Value Value
+	Type  string
+}
+
+func (err *ValueError) Error() string {
+	return "invalid " + err.Type + " value: " + string(err.Value)
+}
+
+// Quote creates quoted string value
+func Quote(s string) Value {
+	return Value("'" + strings.ReplaceAll(s, "'", "''") + "'")
+}
+
+// Int creates integer value
+func Int(i int) Value {
+	return Value(strconv.Itoa(i))
+}
+
+// Float creates real value
+func Float(f float64) Value {
+	return Value(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// String returns unquoted string. Unquoted values are returned as is.
+func (v Value) String() string {
+	s := strings.TrimSpace(string(v))
+	if len(s) >= 2 {
+		q := s[0]
+		if (q == '\'' || q == '"') && s[len(s)-1] == q {
+			return strings.ReplaceAll(s[1:len(s)-1], string([]byte{q, q}), string(q))
+		}
+	}
+	return s
+}
+
+// Int parses integer value. Quoted integers, e.g. '2', are accepted.
+func (v Value) Int() (int, error) {
+	i, err := strconv.Atoi(strings.TrimSpace(v.String()))
+	if err != nil {
+		return 0, &ValueError{Value: v, Type: "integer"}
+	}
+	return i, nil
+}
+
+// Float parses real value, including Fortran exponent 'd'.
+func (v Value) Float() (float64, error) {
+	s := strings.NewReplacer("d", "e", "D", "e").Replace(strings.TrimSpace(v.String()))
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, &ValueError{Value: v, Type: "real"}
+	}
+	return f, nil
+}
+
+// Bool parses switch value, e.g. 'ON', 'YES', .TRUE.
+func (v Value) Bool() (bool, error) {
+	switch strings.ToUpper(strings.Trim(v.String(), ".")) {
+	case "ON", "YES", "TRUE", "T":
+		return true, nil
+	case "OFF", "NO", "FALSE", "F":
+		return false, nil
+	}
+	return false, &ValueError{Value: v, Type: "switch"}
+}
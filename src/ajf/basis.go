@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/philopon/fmoe/ajf/ajf"
+	"github.com/philopon/fmoe/ajf/basisset"
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+// defaultBasisDataPath is basisset.json of the repository, the executable is in bin
+func defaultBasisDataPath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), "..", "basisset.json")
+}
+
+// loadBasisTable reads basisset.json, the aliases and basis sets of the Basis Set Exchange json format.
+// Empty paths are the defaults next to the bin directory, and the aliases are optional if the default is missing.
+func loadBasisTable(dataPath string, aliasesPath string, bsePaths []string) (*basisset.Table, error) {
+	if dataPath == "" {
+		dataPath = defaultBasisDataPath()
+	}
+	data, err := basisset.LoadData(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range bsePaths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		name, functions, err := basisset.ReadBSE(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		data[name] = functions
+	}
+
+	explicit := aliasesPath != ""
+	if !explicit {
+		aliasesPath = defaultBasisSetPath()
+	}
+	aliases, err := basisset.LoadAliases(aliasesPath)
+	if err != nil {
+		if explicit {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "basis set list is not found, aliases are not resolved: %s\n", err.Error())
+		aliases = nil
+	}
+	return &basisset.Table{Data: data, Aliases: aliases}, nil
+}
+
+// basisRow is a fragment or residue of the basis function table
+type basisRow struct {
+	Label    string
+	Atoms    []*pdb.Atom
+	Attached []*pdb.Atom
+}
+
+func countElements(atoms ...[]*pdb.Atom) map[string]int {
+	counts := make(map[string]int)
+	for _, list := range atoms {
+		for _, a := range list {
+			counts[a.Element]++
+		}
+	}
+	return counts
+}
+
+// residueLabel is the most frequent residue of the atoms, e.g. "ALA12"
+func residueLabel(atoms []*pdb.Atom) string {
+	freq := make(map[string]int)
+	label := ""
+	for _, a := range atoms {
+		key := fmt.Sprintf("%s%d", a.ResName, a.ResSeq)
+		freq[key]++
+		if freq[key] > freq[label] {
+			label = key
+		}
+	}
+	return label
+}
+
+// fragmentRows are fragments of &FRAGMENT. BAAs are counted again in their fragments, as the fragment table of the fragmentation panel.
+func fragmentRows(frag *ajf.Fragment, structure *pdb.Structure) ([]basisRow, error) {
+	atom := func(i int) (*pdb.Atom, error) {
+		if i < 1 || i > len(structure.Atoms) {
+			return nil, fmt.Errorf("atom %d is out of the %d atoms of the pdb", i, len(structure.Atoms))
+		}
+		return &structure.Atoms[i-1], nil
+	}
+	rows := make([]basisRow, len(frag.Atoms))
+	for i, indices := range frag.Atoms {
+		for _, index := range indices {
+			a, err := atom(index)
+			if err != nil {
+				return nil, err
+			}
+			rows[i].Atoms = append(rows[i].Atoms, a)
+		}
+		rows[i].Label = residueLabel(rows[i].Atoms)
+	}
+	fragmentOf := frag.FragmentOf()
+	for _, bond := range frag.Bonds {
+		a, err := atom(bond.BAA)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := fragmentOf[bond.BAA]; ok {
+			rows[f-1].Attached = append(rows[f-1].Attached, a)
+		}
+	}
+	return rows, nil
+}
+
+// residueRows are residues of the structure in order of appearance
+func residueRows(structure *pdb.Structure) []basisRow {
+	var rows []basisRow
+	index := make(map[string]int)
+	for i := range structure.Atoms {
+		a := &structure.Atoms[i]
+		key := fmt.Sprintf("%s %s%d%s", a.ResName, a.ChainID, a.ResSeq, a.ICode)
+		j, ok := index[key]
+		if !ok {
+			j = len(rows)
+			index[key] = j
+			rows = append(rows, basisRow{Label: fmt.Sprintf("%s%d", a.ResName, a.ResSeq)})
+		}
+		rows[j].Atoms = append(rows[j].Atoms, a)
+	}
+	return rows
+}
+
+func listBasisSets(table *basisset.Table) {
+	if table.Aliases != nil {
+		for _, name := range table.Aliases.Names() {
+			functions, err := table.Resolve(name)
+			switch {
+			case err != nil:
+				fmt.Printf("%s\tunknown\n", name)
+			case functions == nil:
+				fmt.Printf("%s\tno data\n", name)
+			default:
+				fmt.Printf("%s\t%d elements\n", name, len(functions))
+			}
+		}
+		return
+	}
+	names := make([]string, 0, len(table.Data))
+	for name := range table.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s\t%d elements\n", name, len(table.Data[name]))
+	}
+}
+
+// basis prints number of basis functions of the fragments and the total as tab separated values
+func basis(opts *basisOptions) (int, error) {
+	table, err := loadBasisTable(opts.Data, opts.Aliases, opts.BSE)
+	if err != nil {
+		return ioError, err
+	}
+	if opts.List {
+		listBasisSets(table)
+		return ok, nil
+	}
+
+	name := opts.Name
+	var rows []basisRow
+	var total map[string]int
+	input := opts.Args.Input
+	switch {
+	case opts.Elements != "":
+		if total, err = basisset.ParseElements(opts.Elements); err != nil {
+			return optionParseFailed, err
+		}
+	case strings.EqualFold(filepath.Ext(input), ".ajf"):
+		file, err := readAjf(input)
+		if err != nil {
+			var uerr *ajf.UnterminatedGroup
+			if errors.As(err, &uerr) {
+				return parseError, fmt.Errorf("%s: %w", input, err)
+			}
+			return ioError, err
+		}
+		cntrl, err := file.Cntrl()
+		if err != nil {
+			return parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		if name == "" {
+			if b, err := file.Basis(); err == nil {
+				name = b.BasisSet
+			}
+		}
+		pdbPath := opts.Pdb
+		if pdbPath == "" {
+			if cntrl.ReadGeom == "" {
+				return optionParseFailed, fmt.Errorf("%s: ReadGeom is not specified, use `-p, --pdb'", input)
+			}
+			pdbPath = filepath.Join(filepath.Dir(input), cntrl.ReadGeom)
+		}
+		structure, err := readPdb(pdbPath)
+		if err != nil {
+			var perr *pdb.ParseError
+			if errors.As(err, &perr) {
+				return parseError, fmt.Errorf("%s: %w", pdbPath, err)
+			}
+			return ioError, err
+		}
+		frag, err := file.Fragment()
+		if err != nil {
+			return parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		if frag == nil {
+			rows = residueRows(structure)
+		} else if rows, err = fragmentRows(frag, structure); err != nil {
+			return parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		total = countElements(atomsOf(structure))
+	case input != "":
+		structure, err := readPdb(input)
+		if err != nil {
+			var perr *pdb.ParseError
+			if errors.As(err, &perr) {
+				return parseError, fmt.Errorf("%s: %w", input, err)
+			}
+			return ioError, err
+		}
+		rows = residueRows(structure)
+		total = countElements(atomsOf(structure))
+	default:
+		return optionParseFailed, errors.New("input file or `-e, --elements' is required")
+	}
+	if name == "" && table.Aliases != nil {
+		name = table.Aliases.Default
+	}
+	if name == "" {
+		return optionParseFailed, errors.New("basis set is not specified, use `-n, --name'")
+	}
+
+	functions, err := table.Resolve(name)
+	if err != nil {
+		return invalid, err
+	}
+	if functions == nil {
+		return invalid, fmt.Errorf("basis set %q has no basis function data", name)
+	}
+
+	// rows with uncovered elements are printed with the count of the other elements, and the first error is returned
+	var rowErr error
+	for i, row := range rows {
+		n, err := table.Count(name, countElements(row.Atoms, row.Attached))
+		fmt.Printf("%d\t%s\t%d\t%d\n", i+1, row.Label, len(row.Atoms), n)
+		if err != nil && rowErr == nil {
+			rowErr = fmt.Errorf("%s: %w", row.Label, err)
+		}
+	}
+	atoms := 0
+	for _, n := range total {
+		atoms += n
+	}
+	n, err := table.Count(name, total)
+	fmt.Printf("total\t%s\t%d\t%d\n", name, atoms, n)
+	if err != nil {
+		return invalid, err
+	}
+	if rowErr != nil {
+		return invalid, rowErr
+	}
+	return ok, nil
+}
+
+func atomsOf(structure *pdb.Structure) []*pdb.Atom {
+	atoms := make([]*pdb.Atom, len(structure.Atoms))
+	for i := range structure.Atoms {
+		atoms[i] = &structure.Atoms[i]
+	}
+	return atoms
+}
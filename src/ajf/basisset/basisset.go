@@ -0,0 +1,189 @@
+package basisset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Data is number of basis functions of elements by basis set name, i.e. basisset.json
+type Data map[string]map[string]int
+
+// ReadData reads basisset.json
+func ReadData(reader io.Reader) (Data, error) {
+	var data Data
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// LoadData reads basisset.json
+func LoadData(path string) (Data, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := ReadData(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Aliases is basisset/ajf.json, display names of basis sets to the names of basisset.json.
+// Null alias means the basis set has no data in basisset.json, e.g. 6-31G(C).
+type Aliases struct {
+	Default string             `json:"default"`
+	List    map[string]*string `json:"list"`
+}
+
+// LoadAliases reads basisset/ajf.json
+func LoadAliases(path string) (*Aliases, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var aliases Aliases
+	if err := json.NewDecoder(file).Decode(&aliases); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &aliases, nil
+}
+
+// Lookup finds basis set by the display name or the alias (case insensitive).
+// key is the name in basisset.json, which is empty for basis sets listed as null.
+func (a *Aliases) Lookup(name string) (key string, found bool) {
+	for display, alias := range a.List {
+		if strings.EqualFold(display, name) {
+			if alias == nil {
+				return "", true
+			}
+			return *alias, true
+		}
+	}
+	for _, alias := range a.List {
+		if alias != nil && strings.EqualFold(*alias, name) {
+			return *alias, true
+		}
+	}
+	return "", false
+}
+
+// Names returns sorted display names
+func (a *Aliases) Names() []string {
+	names := make([]string, 0, len(a.List))
+	for name := range a.List {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UnknownBasisSet error
+type UnknownBasisSet struct {
+	Name string
+}
+
+func (err *UnknownBasisSet) Error() string {
+	return fmt.Sprintf("unknown basis set %q", err.Name)
+}
+
+// UncoveredElements error, the basis set has no functions for the elements
+type UncoveredElements struct {
+	Basis    string
+	Elements []string
+}
+
+func (err *UncoveredElements) Error() string {
+	return fmt.Sprintf("basis set %q does not cover %s", err.Basis, strings.Join(err.Elements, ", "))
+}
+
+// Table counts basis functions by basisset.json and the aliases
+type Table struct {
+	Data Data
+	// Aliases may be nil, then names of basisset.json are used
+	Aliases *Aliases
+}
+
+// Resolve returns the elements table of the basis set, by the display name or the name of basisset.json.
+// The table is nil for basis sets listed as null.
+func (t *Table) Resolve(name string) (map[string]int, error) {
+	key := name
+	if t.Aliases != nil {
+		if alias, found := t.Aliases.Lookup(name); found {
+			if alias == "" {
+				return nil, nil
+			}
+			key = alias
+		}
+	}
+	if functions, ok := t.Data[key]; ok {
+		return functions, nil
+	}
+	for k, functions := range t.Data {
+		if strings.EqualFold(k, key) {
+			return functions, nil
+		}
+	}
+	return nil, &UnknownBasisSet{Name: name}
+}
+
+// Count returns number of basis functions of the elements, which maps element symbols to the number of atoms.
+// Elements the basis set does not cover are reported by UncoveredElements with the count of the other elements.
+func (t *Table) Count(name string, elements map[string]int) (int, error) {
+	functions, err := t.Resolve(name)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	var uncovered []string
+	for element, n := range elements {
+		f, ok := functions[NormalizeElement(element)]
+		if !ok {
+			uncovered = append(uncovered, NormalizeElement(element))
+			continue
+		}
+		total += f * n
+	}
+	if len(uncovered) > 0 {
+		sort.Strings(uncovered)
+		return total, &UncoveredElements{Basis: name, Elements: uncovered}
+	}
+	return total, nil
+}
+
+// NormalizeElement capitalizes element symbol, e.g. "CL" to "Cl"
+func NormalizeElement(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+}
+
+// ParseElements parses elements separated by spaces or commas, each of which may have a count, e.g. "C:2 H:6 O"
+func ParseElements(s string) (map[string]int, error) {
+	counts := make(map[string]int)
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	for _, f := range fields {
+		n := 1
+		if i := strings.IndexRune(f, ':'); i >= 0 {
+			var err error
+			if n, err = strconv.Atoi(f[i+1:]); err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid count of element: %q", f)
+			}
+			f = f[:i]
+		}
+		counts[NormalizeElement(f)] += n
+	}
+	return counts, nil
+}
@@ -0,0 +1,76 @@
+package basisset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Shell is a shell of contracted functions. Shells of several angular momenta, e.g. SP, share the exponents.
+type Shell struct {
+	AngularMomentum []int `json:"angular_momentum"`
+	// Coefficients are contraction coefficients of each general contraction
+	Coefficients [][]string `json:"coefficients"`
+}
+
+// NumberOfFunctions counts Cartesian basis functions of the shells, as basisset.py does.
+// A shell of a single angular momentum has a function for each general contraction,
+// and a shell of several angular momenta (e.g. SP) has a function for each angular momentum.
+func NumberOfFunctions(shells []Shell) int {
+	contractions := make(map[int]int)
+	for _, sh := range shells {
+		n := len(sh.Coefficients)
+		if len(sh.AngularMomentum) > 1 {
+			n = 1
+		}
+		for _, am := range sh.AngularMomentum {
+			contractions[am] += n
+		}
+	}
+	total := 0
+	for am, n := range contractions {
+		total += (am + 1) * (am + 2) / 2 * n
+	}
+	return total
+}
+
+// bseBasis is a basis set of the Basis Set Exchange json format
+type bseBasis struct {
+	Name     string `json:"name"`
+	Elements map[string]struct {
+		ElectronShells []Shell `json:"electron_shells"`
+	} `json:"elements"`
+}
+
+// ReadBSE reads a basis set of the Basis Set Exchange json format, and counts basis functions of the elements.
+// name is lower cased as basisset.json.
+func ReadBSE(reader io.Reader) (name string, functions map[string]int, err error) {
+	var basis bseBasis
+	if err := json.NewDecoder(reader).Decode(&basis); err != nil {
+		return "", nil, err
+	}
+	functions = make(map[string]int, len(basis.Elements))
+	for z, element := range basis.Elements {
+		n, err := strconv.Atoi(z)
+		if err != nil || n < 1 || n > len(elementSymbols) {
+			return "", nil, fmt.Errorf("invalid atomic number: %q", z)
+		}
+		if len(element.ElectronShells) == 0 {
+			continue
+		}
+		functions[elementSymbols[n-1]] = NumberOfFunctions(element.ElectronShells)
+	}
+	return strings.ToLower(basis.Name), functions, nil
+}
+
+// elementSymbols by atomic number
+var elementSymbols = strings.Fields(`
+	H He Li Be B C N O F Ne Na Mg Al Si P S Cl Ar K Ca
+	Sc Ti V Cr Mn Fe Co Ni Cu Zn Ga Ge As Se Br Kr Rb Sr Y Zr
+	Nb Mo Tc Ru Rh Pd Ag Cd In Sn Sb Te I Xe Cs Ba La Ce Pr Nd
+	Pm Sm Eu Gd Tb Dy Ho Er Tm Yb Lu Hf Ta W Re Os Ir Pt Au Hg
+	Tl Pb Bi Po At Rn Fr Ra Ac Th Pa U Np Pu Am Cm Bk Cf Es Fm
+	Md No Lr Rf Db Sg Bh Hs Mt Ds Rg Cn Nh Fl Mc Lv Ts Og
+`)
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/philopon/fmoe/ajf/fragmentation"
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+// fragmentSummary is the fragment of the atom as the fragment table: residue, number of atoms, nD, nA and charge
+func fragmentSummary(m *fragmentation.Molecule, result *fragmentation.Result, nD, nA []int, f int) string {
+	atoms := make([]*pdb.Atom, len(result.Fragments[f]))
+	for k, i := range result.Fragments[f] {
+		atoms[k] = m.Atom(i)
+	}
+	return fmt.Sprintf("%s\t%d\t%d\t%d\t%d", residueLabel(atoms), len(atoms), nD[f], nA[f], result.Charges[f]+nD[f]-nA[f])
+}
+
+// covalent proposes detached bonds of covalent ligands, and writes them as tab separated values:
+// BDA and BAA serials, steps from the covalent bond, BDA and BAA atoms, and the fragments of BDA and BAA as the fragment table.
+// The serials are bda_id and baa_id of fmoe_fragmentation, or `ajf split -b BDA:BAA'.
+func covalent(opts *covalentOptions) (int, error) {
+	input := opts.Args.Input
+	if opts.Depth < 0 {
+		return optionParseFailed, fmt.Errorf("invalid depth %d", opts.Depth)
+	}
+	m, charges, bonds, code, err := autoFragmentation(input, "pdb", opts.Charges, opts.Nucleic)
+	if err != nil {
+		return code, err
+	}
+
+	links := m.CovalentLinks(opts.Ligand)
+	if len(links) == 0 {
+		return invalid, fmt.Errorf("%s: %s is not bonded to other residues", input, opts.Ligand)
+	}
+	fmt.Println("#bda\tbaa\tsteps\tbda_atom\tbaa_atom\tbda_residue\tatoms\tnD\tnA\tcharge\tbaa_residue\tatoms\tnD\tnA\tcharge")
+	for _, link := range links {
+		ligand, partner := m.Atom(link.Ligand), m.Atom(link.Partner)
+		fmt.Printf("# %s - %s (%.2f)\n", partner.Label(), ligand.Label(), partner.Distance(ligand))
+		candidates := m.CovalentCandidates(link, bonds, opts.Depth)
+		if len(candidates) == 0 {
+			fmt.Println("# no sp3 carbon to detach")
+		}
+		for _, c := range candidates {
+			result := m.Fragmentate(append(bonds[:len(bonds):len(bonds)], c.Bond), charges)
+			fragmentOf := result.FragmentOf()
+			nD, nA := result.Electrons()
+			bda, baa := m.Atom(c.BDA), m.Atom(c.BAA)
+			fmt.Printf("%d\t%d\t%d\t%s\t%s\t%s\t%s\n", bda.Serial, baa.Serial, c.Steps, bda.Label(), baa.Label(),
+				fragmentSummary(m, result, nD, nA, fragmentOf[c.BDA]), fragmentSummary(m, result, nD, nA, fragmentOf[c.BAA]))
+		}
+	}
+	return ok, nil
+}
@@ -0,0 +1,143 @@
+package fragmentation
+
+import (
+	"errors"
+	"fmt"
+)
+
+var halides = map[string]bool{"F": true, "Cl": true, "Br": true, "I": true}
+
+// ionCharges are charges of monatomic ions in common oxidation states
+var ionCharges = map[string]int{
+	"Li": 1, "Na": 1, "K": 1, "Rb": 1, "Cs": 1, "Ag": 1,
+	"Be": 2, "Mg": 2, "Ca": 2, "Sr": 2, "Ba": 2,
+	"Mn": 2, "Fe": 2, "Co": 2, "Ni": 2, "Cu": 2, "Zn": 2, "Cd": 2, "Hg": 2, "Pb": 2, "Pd": 2, "Pt": 2,
+	"Al": 3, "Ga": 3, "Cr": 3, "La": 3, "Gd": 3, "Eu": 3, "Tb": 3, "Yb": 3, "Lu": 3,
+	"F": -1, "Cl": -1, "Br": -1, "I": -1,
+}
+
+// ErrNoHydrogens is returned if the structure has no hydrogens, which ABINIT-MP requires
+var ErrNoHydrogens = errors.New("no hydrogen atoms, the structure must be protonated")
+
+// FormalCharges assigns formal charges to atoms.
+//
+// Charges of a residue are, in order of precedence, the total charge given by the residue name in overrides,
+// the charge columns of the pdb, charges of monatomic ions, and charged groups found by the hydrogens and bonds:
+// ammonium, carboxylate, amidinium (arginine, protonated histidine), thiolate, phosphate and sulfate.
+// warnings report charges of ions and ligands, which are guessed.
+func (m *Molecule) FormalCharges(overrides map[string]int) (charges []int, warnings []string, err error) {
+	hydrogens := false
+	for _, a := range m.Structure.Atoms {
+		if a.Element == "H" {
+			hydrogens = true
+			break
+		}
+	}
+	if !hydrogens {
+		return nil, nil, ErrNoHydrogens
+	}
+
+	charges = make([]int, len(m.Structure.Atoms))
+	for k := range m.Residues {
+		r := &m.Residues[k]
+		if q, ok := overrides[r.Name]; ok {
+			charges[r.Atoms[0]] = q
+			continue
+		}
+		given := false
+		for _, i := range r.Atoms {
+			if a := m.Atom(i); a.HasCharge {
+				charges[i] = a.Charge
+				given = true
+			}
+		}
+		if given {
+			continue
+		}
+		switch r.Kind {
+		case Water:
+		case Ion:
+			element := m.Atom(r.Atoms[0]).Element
+			q, ok := ionCharges[element]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("%s: unknown charge of %s ion, assumed 0", r.Label(), element))
+			}
+			charges[r.Atoms[0]] = q
+		default:
+			total := 0
+			for _, i := range r.Atoms {
+				charges[i] = m.groupCharge(i)
+				total += charges[i]
+			}
+			if r.Kind == Other {
+				warnings = append(warnings, fmt.Sprintf("%s: charge is estimated as %d", r.Label(), total))
+			}
+		}
+	}
+	return charges, warnings, nil
+}
+
+// terminalOxygens counts oxygens bonded only to the atom
+func (m *Molecule) terminalOxygens(i int) int {
+	n := 0
+	for _, j := range m.Neighbors[i] {
+		if m.Atom(j).Element == "O" && len(m.Neighbors[j]) == 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// groupCharge is charge of the charged group centered at the atom
+func (m *Molecule) groupCharge(i int) int {
+	neighbors := m.Neighbors[i]
+	switch m.Atom(i).Element {
+	case "N":
+		// ammonium, e.g. lysine and N-terminus
+		if len(neighbors) == 4 {
+			return 1
+		}
+	case "C":
+		if len(neighbors) != 3 {
+			return 0
+		}
+		// carboxylate, e.g. aspartate and C-terminus
+		if m.terminalOxygens(i) == 2 {
+			return -1
+		}
+		// amidinium with trivalent nitrogens only, e.g. arginine and protonated histidine
+		nitrogens := 0
+		for _, j := range neighbors {
+			switch m.Atom(j).Element {
+			case "H":
+			case "N":
+				if len(m.Neighbors[j]) != 3 {
+					return 0
+				}
+				nitrogens++
+			default:
+				return 0
+			}
+		}
+		if nitrogens >= 2 {
+			return 1
+		}
+	case "S":
+		// thiolate, e.g. deprotonated cysteine
+		if len(neighbors) == 1 && m.Atom(neighbors[0]).Element == "C" && len(m.Neighbors[neighbors[0]]) == 4 {
+			return -1
+		}
+		// sulfonate and sulfate
+		if len(neighbors) == 4 {
+			if n := m.terminalOxygens(i); n >= 3 {
+				return 2 - n
+			}
+		}
+	case "P":
+		// phosphate, e.g. phosphodiester of nucleic acids
+		if n := m.terminalOxygens(i); n >= 2 {
+			return 1 - n
+		}
+	}
+	return 0
+}
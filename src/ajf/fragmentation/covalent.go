@@ -0,0 +1,139 @@
+package fragmentation
+
+import (
+	"sort"
+)
+
+// CovalentLink is a bond between a ligand residue and another residue by 0-origin positions
+type CovalentLink struct {
+	Ligand  int
+	Partner int
+}
+
+// CovalentLinks finds bonds between residues named name and other residues, except water and ions
+func (m *Molecule) CovalentLinks(name string) []CovalentLink {
+	var links []CovalentLink
+	for r := range m.Residues {
+		if m.Residues[r].Name != name {
+			continue
+		}
+		for _, i := range m.Residues[r].Atoms {
+			for _, j := range m.Neighbors[i] {
+				other := &m.Residues[m.ResidueOf[j]]
+				if other.Name == name || other.Kind == Water || other.Kind == Ion {
+					continue
+				}
+				links = append(links, CovalentLink{Ligand: i, Partner: j})
+			}
+		}
+	}
+	return links
+}
+
+// isSp3Carbon reports whether the atom is a carbon of 4 bonded atoms, as aGeometry of protonated structures
+func (m *Molecule) isSp3Carbon(i int) bool {
+	return m.Atom(i).Element == "C" && len(m.Neighbors[i]) == 4
+}
+
+// Candidate is a proposed detached bond
+type Candidate struct {
+	Bond
+	// Steps is the number of bonds from the covalent link to the farther atom, 0 for the link itself
+	Steps int
+}
+
+// anchor is the atom staying in the fragment of the partner residue, CA of amino residues or the partner atom
+func (m *Molecule) anchor(link CovalentLink) int {
+	r := &m.Residues[m.ResidueOf[link.Partner]]
+	if r.Kind == Amino {
+		if ca := m.Find(r, "CA"); ca >= 0 {
+			return ca
+		}
+	}
+	return link.Partner
+}
+
+// ligandFragment returns the fragment having the most atoms of the ligand residue
+func (m *Molecule) ligandFragment(link CovalentLink, fragmentOf map[int]int) int {
+	counts := make(map[int]int)
+	best := fragmentOf[link.Ligand]
+	for _, i := range m.Residues[m.ResidueOf[link.Ligand]].Atoms {
+		f := fragmentOf[i]
+		counts[f]++
+		if counts[f] > counts[best] || (counts[f] == counts[best] && f < best) {
+			best = f
+		}
+	}
+	return best
+}
+
+// CovalentCandidates proposes bonds separating the ligand from the partner residue within depth steps from the link,
+// in addition to the detached bonds. The BDA is an sp3 carbon, and the BAA is a non-hydrogen neighbor, as BondPicker suggests.
+// Bonds in rings do not separate the fragments, and are not proposed.
+// Candidates are ordered by the steps and the BDA.
+func (m *Molecule) CovalentCandidates(link CovalentLink, bonds []Bond, depth int) []Candidate {
+	scope := map[int]bool{m.ResidueOf[link.Ligand]: true, m.ResidueOf[link.Partner]: true}
+	steps := map[int]int{link.Ligand: 0, link.Partner: 0}
+	queue := []int{link.Ligand, link.Partner}
+	for k := 0; k < len(queue); k++ {
+		i := queue[k]
+		if steps[i] >= depth {
+			continue
+		}
+		for _, j := range m.Neighbors[i] {
+			if _, ok := steps[j]; !ok && scope[m.ResidueOf[j]] {
+				steps[j] = steps[i] + 1
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	detached := make(map[[2]int]bool, len(bonds))
+	for _, b := range bonds {
+		detached[[2]int{b.BDA, b.BAA}] = true
+		detached[[2]int{b.BAA, b.BDA}] = true
+	}
+	anchor := m.anchor(link)
+	var result []Candidate
+	for bda, s := range steps {
+		if !m.isSp3Carbon(bda) {
+			continue
+		}
+		for _, baa := range m.Neighbors[bda] {
+			t, ok := steps[baa]
+			if !ok || m.Atom(baa).Element == "H" || detached[[2]int{bda, baa}] {
+				continue
+			}
+			fragmentOf := m.fragmentOf(append(bonds[:len(bonds):len(bonds)], Bond{BDA: bda, BAA: baa}))
+			if fragmentOf[bda] == fragmentOf[baa] || fragmentOf[anchor] == m.ligandFragment(link, fragmentOf) {
+				continue
+			}
+			c := Candidate{Bond: Bond{BDA: bda, BAA: baa}, Steps: s}
+			if t > s {
+				c.Steps = t
+			}
+			result = append(result, c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Steps != result[j].Steps {
+			return result[i].Steps < result[j].Steps
+		}
+		if result[i].BDA != result[j].BDA {
+			return result[i].BDA < result[j].BDA
+		}
+		return result[i].BAA < result[j].BAA
+	})
+	return result
+}
+
+// fragmentOf returns the fragment position of each atom divided by the bonds
+func (m *Molecule) fragmentOf(bonds []Bond) map[int]int {
+	result := make(map[int]int, len(m.Neighbors))
+	for f, atoms := range m.Partition(bonds) {
+		for _, i := range atoms {
+			result[i] = f
+		}
+	}
+	return result
+}
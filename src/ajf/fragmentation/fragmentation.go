@@ -0,0 +1,76 @@
+// Package fragmentation divides a pdb structure into fragments of ABINIT-MP by the rules of the fragmentation panel
+package fragmentation
+
+import (
+	"github.com/philopon/fmoe/ajf/ajf"
+)
+
+// AutoBonds returns detached bonds of the automatic fragmentation, as FMOFragmentation of the fragmentation panel
+func (m *Molecule) AutoBonds(scheme NucleicScheme) []Bond {
+	bonds := append(m.ProteinBonds(), m.NucleicBonds(scheme)...)
+	return m.mergeCTerminals(bonds)
+}
+
+// Result is fragments by 0-origin atom positions
+type Result struct {
+	Fragments [][]int
+	Bonds     []Bond
+	// Charges are formal charges of the fragments
+	Charges []int
+}
+
+// Fragmentate divides the molecule into fragments by the bonds, and sums up the atom charges of each fragment
+func (m *Molecule) Fragmentate(bonds []Bond, charges []int) *Result {
+	fragments := m.Partition(bonds)
+	result := Result{Fragments: fragments, Bonds: bonds, Charges: make([]int, len(fragments))}
+	for f, atoms := range fragments {
+		for _, i := range atoms {
+			result.Charges[f] += charges[i]
+		}
+	}
+	return &result
+}
+
+// FragmentOf returns the fragment position of each atom
+func (r *Result) FragmentOf() map[int]int {
+	result := make(map[int]int)
+	for f, atoms := range r.Fragments {
+		for _, i := range atoms {
+			result[i] = f
+		}
+	}
+	return result
+}
+
+// Electrons counts the detached bonds of each fragment, nD for BDAs and nA for BAAs, as FormatFragments
+func (r *Result) Electrons() (nD, nA []int) {
+	fragmentOf := r.FragmentOf()
+	nD = make([]int, len(r.Fragments))
+	nA = make([]int, len(r.Fragments))
+	for _, b := range r.Bonds {
+		nD[fragmentOf[b.BDA]]++
+		nA[fragmentOf[b.BAA]]++
+	}
+	return nD, nA
+}
+
+// Fragment builds &FRAGMENT of the result
+func (r *Result) Fragment(m *Molecule) (*ajf.Fragment, error) {
+	serial := func(i int) int { return m.Atom(i).Serial }
+	atoms := make([]int, len(m.Structure.Atoms))
+	for i := range atoms {
+		atoms[i] = serial(i)
+	}
+	fragments := make([][]int, len(r.Fragments))
+	for f, indices := range r.Fragments {
+		fragments[f] = make([]int, len(indices))
+		for k, i := range indices {
+			fragments[f][k] = serial(i)
+		}
+	}
+	bonds := make([]ajf.Bond, len(r.Bonds))
+	for k, b := range r.Bonds {
+		bonds[k] = ajf.Bond{BDA: serial(b.BDA), BAA: serial(b.BAA)}
+	}
+	return ajf.NewFragment(atoms, fragments, r.Charges, bonds)
+}
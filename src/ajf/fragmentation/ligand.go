@@ -0,0 +1,151 @@
+package fragmentation
+
+import (
+	"sort"
+)
+
+// bondOrder returns the order of the bond of SDF and MOL2 files, or 0 if unknown
+func (m *Molecule) bondOrder(i, j int) int {
+	if m.Structure.Orders == nil {
+		return 0
+	}
+	return m.Structure.BondOrder(m.Atom(i).Serial, m.Atom(j).Serial)
+}
+
+// isUnsaturated reports whether the atom has a multiple or aromatic bond.
+// The bond orders are used if any, otherwise atoms of fewer bonded atoms than their valences, e.g. C of 3 bonded atoms, are unsaturated.
+func (m *Molecule) isUnsaturated(i int) bool {
+	if m.Structure.Orders != nil {
+		for _, j := range m.Neighbors[i] {
+			if m.bondOrder(i, j) > 1 {
+				return true
+			}
+		}
+		return false
+	}
+	n := len(m.Neighbors[i])
+	switch m.Atom(i).Element {
+	case "C":
+		return n < 4
+	case "N":
+		return n < 3
+	case "O":
+		return n < 2
+	}
+	return false
+}
+
+// isSaturatedCarbon reports whether the atom is an sp3 carbon, whose neighbors are not unsaturated
+func (m *Molecule) isSaturatedCarbon(i int) bool {
+	if m.Atom(i).Element != "C" || m.isUnsaturated(i) {
+		return false
+	}
+	for _, j := range m.Neighbors[i] {
+		if m.isUnsaturated(j) {
+			return false
+		}
+	}
+	return true
+}
+
+// side returns the atoms of the fragment connected to start without the bond, or all atoms of the fragment for bonds in rings
+func (m *Molecule) side(fragment map[int]bool, start int, bond Bond) []int {
+	visited := map[int]bool{start: true}
+	queue := []int{start}
+	for k := 0; k < len(queue); k++ {
+		i := queue[k]
+		for _, j := range m.Neighbors[i] {
+			if visited[j] || !fragment[j] || (i == bond.BDA && j == bond.BAA) || (i == bond.BAA && j == bond.BDA) {
+				continue
+			}
+			visited[j] = true
+			queue = append(queue, j)
+		}
+	}
+	return queue
+}
+
+// LigandCandidates returns single C-C bonds between sp3 carbons among the atoms,
+// which are not in rings, nor next to multiple or aromatic bonds.
+// Bonds are ordered by BDA and BAA, and the BDA is the former atom.
+func (m *Molecule) LigandCandidates(atoms []int) []Bond {
+	scope := make(map[int]bool, len(atoms))
+	for _, i := range atoms {
+		scope[i] = true
+	}
+	var result []Bond
+	for _, i := range atoms {
+		if !m.isSaturatedCarbon(i) {
+			continue
+		}
+		for _, j := range m.Neighbors[i] {
+			if j <= i || !scope[j] || !m.isSaturatedCarbon(j) {
+				continue
+			}
+			if order := m.bondOrder(i, j); order > 1 {
+				continue
+			}
+			b := Bond{BDA: i, BAA: j}
+			if len(m.side(scope, i, b)) < len(atoms) {
+				result = append(result, b)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BDA != result[j].BDA {
+			return result[i].BDA < result[j].BDA
+		}
+		return result[i].BAA < result[j].BAA
+	})
+	return result
+}
+
+// LigandBonds selects the candidates dividing the atoms into fragments of about size atoms, hydrogens included.
+// The largest fragment over the size is divided at the most even candidate repeatedly,
+// while both of the divided fragments have half of the size at least.
+// The BDA of each bond is in the larger side.
+func (m *Molecule) LigandBonds(atoms []int, size int) []Bond {
+	candidates := m.LigandCandidates(atoms)
+	var bonds []Bond
+	done := make(map[int]bool)
+	for {
+		fragments := m.PartitionAtoms(atoms, bonds)
+		sort.SliceStable(fragments, func(i, j int) bool { return len(fragments[i]) > len(fragments[j]) })
+		divided := false
+		for _, fragment := range fragments {
+			if len(fragment) <= size || done[fragment[0]] {
+				continue
+			}
+			scope := make(map[int]bool, len(fragment))
+			for _, i := range fragment {
+				scope[i] = true
+			}
+			best, bestSize := Bond{}, len(fragment)
+			for _, c := range candidates {
+				if !scope[c.BDA] || !scope[c.BAA] {
+					continue
+				}
+				n := len(m.side(scope, c.BDA, c))
+				larger, smaller := n, len(fragment)-n
+				b := c
+				if smaller > larger {
+					larger, smaller = smaller, larger
+					b = Bond{BDA: c.BAA, BAA: c.BDA}
+				}
+				if 2*smaller >= size && larger < bestSize {
+					best, bestSize = b, larger
+				}
+			}
+			if bestSize == len(fragment) {
+				done[fragment[0]] = true
+				continue
+			}
+			bonds = append(bonds, best)
+			divided = true
+			break
+		}
+		if !divided {
+			return bonds
+		}
+	}
+}
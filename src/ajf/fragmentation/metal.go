@@ -0,0 +1,183 @@
+package fragmentation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MetalRule is the distance cutoff in angstrom between a metal and coordinating atoms of the elements
+type MetalRule struct {
+	Metal    string   `json:"metal"`
+	Elements []string `json:"elements"`
+	Cutoff   float64  `json:"cutoff"`
+}
+
+// DefaultMetalRules are cutoffs about 0.3-0.5 angstrom longer than typical coordination bonds in proteins
+var DefaultMetalRules = []MetalRule{
+	{Metal: "Zn", Elements: []string{"S"}, Cutoff: 2.7},
+	{Metal: "Zn", Elements: []string{"N", "O"}, Cutoff: 2.5},
+	{Metal: "Fe", Elements: []string{"N", "O"}, Cutoff: 2.5},
+	{Metal: "Fe", Elements: []string{"S"}, Cutoff: 2.7},
+	{Metal: "Cu", Elements: []string{"N", "O"}, Cutoff: 2.5},
+	{Metal: "Cu", Elements: []string{"S"}, Cutoff: 2.8},
+	{Metal: "Mn", Elements: []string{"N", "O"}, Cutoff: 2.6},
+	{Metal: "Co", Elements: []string{"N", "O", "S"}, Cutoff: 2.6},
+	{Metal: "Ni", Elements: []string{"N", "O", "S"}, Cutoff: 2.6},
+	{Metal: "Cd", Elements: []string{"N", "O"}, Cutoff: 2.7},
+	{Metal: "Cd", Elements: []string{"S"}, Cutoff: 2.9},
+	{Metal: "Mg", Elements: []string{"O"}, Cutoff: 2.5},
+	{Metal: "Ca", Elements: []string{"O"}, Cutoff: 2.8},
+	{Metal: "Na", Elements: []string{"O"}, Cutoff: 2.8},
+	{Metal: "K", Elements: []string{"O"}, Cutoff: 3.2},
+}
+
+// ReadMetalRules reads json array of rules, e.g. [{"metal": "Zn", "elements": ["S"], "cutoff": 2.7}]
+func ReadMetalRules(reader io.Reader) ([]MetalRule, error) {
+	var rules []MetalRule
+	dec := json.NewDecoder(reader)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return nil, err
+	}
+	for i, r := range rules {
+		if r.Metal == "" || len(r.Elements) == 0 || r.Cutoff <= 0 {
+			return nil, fmt.Errorf("rule %d: metal, elements and positive cutoff are required", i+1)
+		}
+	}
+	return rules, nil
+}
+
+// Coordination is a metal atom and its coordinating atoms by 0-origin positions
+type Coordination struct {
+	Metal   int
+	Ligands []int
+}
+
+// cutoff returns the longest cutoff of the rules for the metal and the element, or 0
+func cutoff(rules []MetalRule, metal string, element string) float64 {
+	result := 0.0
+	for _, r := range rules {
+		if r.Metal != metal {
+			continue
+		}
+		for _, e := range r.Elements {
+			if e == element && r.Cutoff > result {
+				result = r.Cutoff
+			}
+		}
+	}
+	return result
+}
+
+// Coordinations finds atoms coordinating metals within the cutoffs of the rules
+func (m *Molecule) Coordinations(rules []MetalRule) []Coordination {
+	var result []Coordination
+	for i := range m.Structure.Atoms {
+		metal := m.Atom(i)
+		if !IsMetal(metal.Element) {
+			continue
+		}
+		c := Coordination{Metal: i}
+		for j := range m.Structure.Atoms {
+			a := m.Atom(j)
+			if d := cutoff(rules, metal.Element, a.Element); d > 0 && metal.Distance(a) <= d {
+				c.Ligands = append(c.Ligands, j)
+			}
+		}
+		if len(c.Ligands) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// MergeList returns groups of 1-origin fragment numbers to merge the metals with the fragments of their coordinating atoms.
+// Overlapping groups are joined, and each group is sorted and the groups are ordered by the first fragments, as appendMergeList.
+func (r *Result) MergeList(coordinations []Coordination) [][]int {
+	fragmentOf := r.FragmentOf()
+	parent := make([]int, len(r.Fragments))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, c := range coordinations {
+		for _, l := range c.Ligands {
+			a, b := find(fragmentOf[c.Metal]), find(fragmentOf[l])
+			if a != b {
+				parent[b] = a
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for f := range r.Fragments {
+		root := find(f)
+		groups[root] = append(groups[root], f+1)
+	}
+	var list [][]int
+	for _, g := range groups {
+		if len(g) > 1 {
+			list = append(list, g)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i][0] < list[j][0] })
+	return list
+}
+
+// Merge merges fragments by the merge list of 1-origin fragment numbers, as mergeFragments of the fragmentation panel.
+// Merged fragments are ordered by their first fragments. Unlike the panel, bonds detached inside a merged fragment are restored.
+func (r *Result) Merge(list [][]int) *Result {
+	target := make([]int, len(r.Fragments))
+	for i := range target {
+		target[i] = i
+	}
+	for _, g := range list {
+		for _, f := range g[1:] {
+			target[f-1] = g[0] - 1
+		}
+	}
+
+	position := make(map[int]int)
+	var result Result
+	for f, atoms := range r.Fragments {
+		p, ok := position[target[f]]
+		if !ok {
+			p = len(result.Fragments)
+			position[target[f]] = p
+			result.Fragments = append(result.Fragments, nil)
+			result.Charges = append(result.Charges, 0)
+		}
+		result.Fragments[p] = append(result.Fragments[p], atoms...)
+		result.Charges[p] += r.Charges[f]
+	}
+	for _, atoms := range result.Fragments {
+		sort.Ints(atoms)
+	}
+	fragmentOf := result.FragmentOf()
+	for _, b := range r.Bonds {
+		if fragmentOf[b.BDA] != fragmentOf[b.BAA] {
+			result.Bonds = append(result.Bonds, b)
+		}
+	}
+	return &result
+}
+
+// FormatMergeList formats the merge list as FormatMergeList of the fragmentation panel,
+// a line of serial number, group number and fragment number for each fragment in the groups
+func FormatMergeList(list [][]int) []string {
+	var lines []string
+	for i, g := range list {
+		for _, f := range g {
+			lines = append(lines, fmt.Sprintf("%d\t%d\t%d", len(lines)+1, i+1, f))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,85 @@
+package fragmentation
+
+import "fmt"
+
+// NucleicScheme is fragmentation scheme of nucleic acids
+type NucleicScheme int
+
+// nucleic acid schemes
+const (
+	// PlusBase divides nucleotides and their bases, as FragSizeNucleotide='+base' of ABINIT-MP and the fragmentation panel
+	PlusBase NucleicScheme = iota
+	// Nucleotide divides nucleotides only
+	Nucleotide
+	// SugarPhosphate divides bases, sugars and phosphates
+	SugarPhosphate
+)
+
+var nucleicSchemeNames = []string{"+base", "nucleotide", "sugar-phosphate"}
+
+func (s NucleicScheme) String() string {
+	return nucleicSchemeNames[s]
+}
+
+// ParseNucleicScheme parses scheme name, "+base", "nucleotide" or "sugar-phosphate"
+func ParseNucleicScheme(name string) (NucleicScheme, error) {
+	for i, n := range nucleicSchemeNames {
+		if n == name {
+			return NucleicScheme(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown nucleic acid scheme %q", name)
+}
+
+// neighborNamed returns the neighbor of the atom named one of the names, or -1
+func (m *Molecule) neighborNamed(i int, names ...string) int {
+	for _, j := range m.Neighbors[i] {
+		for _, name := range names {
+			if m.Atom(j).Name == name {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// NucleicBonds returns detached bonds of nucleic acids, as FMOFragmentationNucleicAcid of the fragmentation panel.
+//
+// Backbones are cut at C5' (BDA) and C4' (BAA) except the first residue of each chain (Residue.Number),
+// and bases are cut at C1' (BDA) and N1 or N9 (BAA) with the PlusBase scheme.
+// The SugarPhosphate scheme cuts C5'-O5' and C3'-O3' next to phosphorus instead of C5'-C4'.
+func (m *Molecule) NucleicBonds(scheme NucleicScheme) []Bond {
+	var bonds []Bond
+	for k := range m.Residues {
+		r := &m.Residues[k]
+		if r.Kind != Nucleic {
+			continue
+		}
+		// the panel skips residues of rNumber 1, which are 5' terminal residues of nucleic acid chains
+		terminal := r.Number == 1
+
+		switch scheme {
+		case SugarPhosphate:
+			for _, pair := range [][2]string{{"C5'", "O5'"}, {"C3'", "O3'"}} {
+				c, o := m.Find(r, pair[0]), m.Find(r, pair[1])
+				if c >= 0 && o >= 0 && m.neighborNamed(o, "P") >= 0 {
+					bonds = append(bonds, Bond{BDA: c, BAA: o})
+				}
+			}
+		default:
+			c5, c4 := m.Find(r, "C5'"), m.Find(r, "C4'")
+			if !terminal && c5 >= 0 && c4 >= 0 {
+				bonds = append(bonds, Bond{BDA: c5, BAA: c4})
+			}
+		}
+
+		if scheme == PlusBase || scheme == SugarPhosphate {
+			if c1 := m.Find(r, "C1'"); c1 >= 0 {
+				if n := m.neighborNamed(c1, "N1", "N9"); n >= 0 {
+					bonds = append(bonds, Bond{BDA: c1, BAA: n})
+				}
+			}
+		}
+	}
+	return bonds
+}
@@ -0,0 +1,81 @@
+package fragmentation
+
+// ProteinBonds returns CA (BDA) and C (BAA) bonds of amino residues, as FMOFragmentationProtein of the fragmentation panel.
+// Chains with other residues in the backbone, e.g. peptide ligands with capping groups, are not divided.
+// Other residues off the backbone, e.g. ligands, water, ions and covalent ligands bonded to side chains, are not counted,
+// as they are in their own chains of MOE.
+func (m *Molecule) ProteinBonds() []Bond {
+	protein := make(map[string]bool)
+	for i := range m.Residues {
+		r := &m.Residues[i]
+		if _, ok := protein[r.ChainID]; !ok {
+			protein[r.ChainID] = true
+		}
+		if r.Kind != Amino && m.inBackbone(i) {
+			protein[r.ChainID] = false
+		}
+	}
+	var bonds []Bond
+	for i := range m.Residues {
+		r := &m.Residues[i]
+		if r.Kind != Amino || !protein[r.ChainID] {
+			continue
+		}
+		ca, c := m.Find(r, "CA"), m.Find(r, "C")
+		if ca >= 0 && c >= 0 {
+			bonds = append(bonds, Bond{BDA: ca, BAA: c})
+		}
+	}
+	return bonds
+}
+
+// inBackbone reports whether the residue is bonded to N or C of an amino residue
+func (m *Molecule) inBackbone(r int) bool {
+	for _, i := range m.Residues[r].Atoms {
+		for _, j := range m.Neighbors[i] {
+			other := m.ResidueOf[j]
+			if other == r || m.Residues[other].Kind != Amino {
+				continue
+			}
+			if name := m.Atom(j).Name; name == "N" || name == "C" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCTerminal reports whether the fragment is a lone terminal COO- or COOH of amino residues, as IsCTerminalFragment.
+// Elements are used instead of MM types of MOE.
+func (m *Molecule) isCTerminal(fragment []int) bool {
+	counts := make(map[string]int)
+	for _, i := range fragment {
+		if m.Residues[m.ResidueOf[i]].Kind != Amino {
+			return false
+		}
+		counts[m.Atom(i).Element]++
+	}
+	if counts["C"] != 1 || counts["O"] != 2 {
+		return false
+	}
+	return len(fragment) == 3 || (len(fragment) == 4 && counts["H"] == 1)
+}
+
+// mergeCTerminals removes the bonds detaching lone C-terminal fragments, as FMOFragmentation
+func (m *Molecule) mergeCTerminals(bonds []Bond) []Bond {
+	terminal := make(map[int]bool)
+	for _, fragment := range m.Partition(bonds) {
+		if m.isCTerminal(fragment) {
+			for _, i := range fragment {
+				terminal[i] = true
+			}
+		}
+	}
+	var result []Bond
+	for _, b := range bonds {
+		if !terminal[b.BDA] && !terminal[b.BAA] {
+			result = append(result, b)
+		}
+	}
+	return result
+}
@@ -0,0 +1,275 @@
+package fragmentation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+// Kind is residue type as rType of MOE
+type Kind int
+
+// residue kinds
+const (
+	Other Kind = iota
+	Amino
+	Nucleic
+	Water
+	Ion
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Amino:
+		return "amino"
+	case Nucleic:
+		return "nucleic"
+	case Water:
+		return "water"
+	case Ion:
+		return "ion"
+	}
+	return "other"
+}
+
+var waterNames = map[string]bool{"HOH": true, "WAT": true, "DOD": true, "H2O": true, "TIP": true, "TIP3": true, "SOL": true}
+
+// Residue is atoms of a residue
+type Residue struct {
+	Name    string
+	ChainID string
+	ResSeq  int
+	ICode   string
+	Kind    Kind
+	// Number is 1-origin position in the chain, as rNumber of MOE.
+	// Chains are separated by chain IDs and TER records.
+	Number int
+	// Atoms are 0-origin positions in the structure
+	Atoms []int
+}
+
+// Label is human readable residue label, e.g. "SER A1"
+func (r *Residue) Label() string {
+	return fmt.Sprintf("%s %s%d%s", r.Name, r.ChainID, r.ResSeq, r.ICode)
+}
+
+// Molecule is a structure with residues and the bond graph
+type Molecule struct {
+	Structure *pdb.Structure
+	Residues  []Residue
+	// ResidueOf is the residue position of each atom
+	ResidueOf []int
+	// Neighbors are bonded atoms of each atom by 0-origin positions
+	Neighbors [][]int
+}
+
+// metals are not bonded by distance, as MOE does not bond them on reading pdb files
+var metals = map[string]bool{
+	"Li": true, "Na": true, "K": true, "Rb": true, "Cs": true,
+	"Be": true, "Mg": true, "Ca": true, "Sr": true, "Ba": true,
+	"Al": true, "Ga": true, "Sn": true, "Pb": true,
+	"Sc": true, "Ti": true, "V": true, "Cr": true, "Mn": true, "Fe": true, "Co": true, "Ni": true, "Cu": true, "Zn": true,
+	"Y": true, "Zr": true, "Mo": true, "Ru": true, "Rh": true, "Pd": true, "Ag": true, "Cd": true,
+	"W": true, "Re": true, "Os": true, "Ir": true, "Pt": true, "Au": true, "Hg": true,
+	"La": true, "Ce": true, "Eu": true, "Gd": true, "Tb": true, "Yb": true, "Lu": true,
+}
+
+// IsMetal reports whether the element is a metal
+func IsMetal(element string) bool {
+	return metals[element]
+}
+
+// NewMolecule groups atoms into residues and builds the bond graph
+func NewMolecule(s *pdb.Structure) *Molecule {
+	m := Molecule{Structure: s, ResidueOf: make([]int, len(s.Atoms))}
+	index := make(map[string]int)
+	for i := range s.Atoms {
+		a := &s.Atoms[i]
+		key := fmt.Sprintf("%d/%s/%s/%d/%s", a.Ter, a.ChainID, a.ResName, a.ResSeq, a.ICode)
+		r, ok := index[key]
+		if !ok {
+			r = len(m.Residues)
+			index[key] = r
+			number := 1
+			if r > 0 {
+				prev := m.Atom(m.Residues[r-1].Atoms[0])
+				if prev.ChainID == a.ChainID && prev.Ter == a.Ter {
+					number = m.Residues[r-1].Number + 1
+				}
+			}
+			m.Residues = append(m.Residues, Residue{Name: a.ResName, ChainID: a.ChainID, ResSeq: a.ResSeq, ICode: a.ICode, Number: number})
+		}
+		m.Residues[r].Atoms = append(m.Residues[r].Atoms, i)
+		m.ResidueOf[i] = r
+	}
+	m.Neighbors = bondGraph(s)
+	for i := range m.Residues {
+		m.Residues[i].Kind = m.kindOf(&m.Residues[i])
+	}
+	return &m
+}
+
+// Atom returns the atom at the 0-origin position
+func (m *Molecule) Atom(i int) *pdb.Atom {
+	return &m.Structure.Atoms[i]
+}
+
+// Find returns the atom named name in the residue, or -1
+func (m *Molecule) Find(r *Residue, name string) int {
+	for _, i := range r.Atoms {
+		if m.Structure.Atoms[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsBonded reports whether the atoms are bonded
+func (m *Molecule) IsBonded(i, j int) bool {
+	for _, k := range m.Neighbors[i] {
+		if k == j {
+			return true
+		}
+	}
+	return false
+}
+
+// kindOf classifies residue by its atoms. HETATM residues are not amino acids nor nucleotides, as MOE keeps ligands in a fragment.
+func (m *Molecule) kindOf(r *Residue) Kind {
+	switch {
+	case waterNames[r.Name]:
+		return Water
+	case len(r.Atoms) == 1 && (IsMetal(m.Atom(r.Atoms[0]).Element) || halides[m.Atom(r.Atoms[0]).Element]):
+		return Ion
+	case !m.Atom(r.Atoms[0]).HetAtm && m.Find(r, "N") >= 0 && m.Find(r, "CA") >= 0 && m.Find(r, "C") >= 0:
+		return Amino
+	case !m.Atom(r.Atoms[0]).HetAtm && m.Find(r, "C1'") >= 0 && m.Find(r, "C4'") >= 0 && m.Find(r, "C5'") >= 0:
+		return Nucleic
+	}
+	return Other
+}
+
+// gridSize is larger than the longest bond by covalent radii
+const gridSize = 3.5
+
+// bondGraph bonds atoms by CONECT records and covalent radii, except metals bonded only by CONECT records
+func bondGraph(s *pdb.Structure) [][]int {
+	neighbors := make([][]int, len(s.Atoms))
+	bonded := make(map[[2]int]bool)
+	bond := func(i, j int) {
+		if i == j {
+			return
+		}
+		if i > j {
+			i, j = j, i
+		}
+		if !bonded[[2]int{i, j}] {
+			bonded[[2]int{i, j}] = true
+			neighbors[i] = append(neighbors[i], j)
+			neighbors[j] = append(neighbors[j], i)
+		}
+	}
+
+	cells := make(map[[3]int][]int)
+	cellOf := func(a *pdb.Atom) [3]int {
+		return [3]int{int(math.Floor(a.X / gridSize)), int(math.Floor(a.Y / gridSize)), int(math.Floor(a.Z / gridSize))}
+	}
+	for i := range s.Atoms {
+		c := cellOf(&s.Atoms[i])
+		cells[c] = append(cells[c], i)
+	}
+	for i := range s.Atoms {
+		a := &s.Atoms[i]
+		if IsMetal(a.Element) {
+			continue
+		}
+		c := cellOf(a)
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				for dz := -1; dz <= 1; dz++ {
+					for _, j := range cells[[3]int{c[0] + dx, c[1] + dy, c[2] + dz}] {
+						if j <= i || IsMetal(s.Atoms[j].Element) {
+							continue
+						}
+						if s.Bonded(a, &s.Atoms[j]) {
+							bond(i, j)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	positions := make(map[int]int, len(s.Atoms))
+	for i, a := range s.Atoms {
+		positions[a.Serial] = i
+	}
+	for serial, serials := range s.Conect {
+		i, ok := positions[serial]
+		if !ok {
+			continue
+		}
+		for _, other := range serials {
+			if j, ok := positions[other]; ok {
+				bond(i, j)
+			}
+		}
+	}
+	for _, n := range neighbors {
+		sort.Ints(n)
+	}
+	return neighbors
+}
+
+// Bond is a detached bond by 0-origin positions of BDA and BAA
+type Bond struct {
+	BDA int
+	BAA int
+}
+
+// Partition divides atoms into fragments by cutting the bonds, as PartitionAtoms of the fragmentation panel.
+// Fragments are ordered by their first atoms, and atoms in a fragment are sorted.
+func (m *Molecule) Partition(bonds []Bond) [][]int {
+	atoms := make([]int, len(m.Neighbors))
+	for i := range atoms {
+		atoms[i] = i
+	}
+	return m.PartitionAtoms(atoms, bonds)
+}
+
+// PartitionAtoms divides the atoms into fragments as Partition, ignoring bonds to the other atoms
+func (m *Molecule) PartitionAtoms(atoms []int, bonds []Bond) [][]int {
+	cut := make(map[[2]int]bool, len(bonds))
+	for _, b := range bonds {
+		cut[[2]int{b.BDA, b.BAA}] = true
+		cut[[2]int{b.BAA, b.BDA}] = true
+	}
+	sorted := append([]int(nil), atoms...)
+	sort.Ints(sorted)
+	fragmentOf := make(map[int]int, len(sorted))
+	for _, i := range sorted {
+		fragmentOf[i] = -1
+	}
+	var fragments [][]int
+	for _, start := range sorted {
+		if fragmentOf[start] >= 0 {
+			continue
+		}
+		f := len(fragments)
+		fragment := []int{start}
+		fragmentOf[start] = f
+		for k := 0; k < len(fragment); k++ {
+			for _, j := range m.Neighbors[fragment[k]] {
+				if g, ok := fragmentOf[j]; ok && g < 0 && !cut[[2]int{fragment[k], j}] {
+					fragmentOf[j] = f
+					fragment = append(fragment, j)
+				}
+			}
+		}
+		sort.Ints(fragment)
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
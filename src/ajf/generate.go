@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/philopon/fmoe/ajf/ajf"
+)
+
+// FragmentDescription is JSON description of fragmentation, e.g.
+//
+//	{
+//	  "atoms": [1, 2, 3, 4, 5, 6],
+//	  "fragments": [[1, 2, 3], [4, 5, 6]],
+//	  "charges": [0, -1],
+//	  "bonds": [[2, 4]]
+//	}
+//
+// atoms is the order of atoms in the ReadGeom file and may be omitted if the pdb is given,
+// or the serials are in ascending order. charges are formal charges of fragments and default to 0.
+// bonds are [BDA, BAA] pairs. All atoms are given by the serials.
+type FragmentDescription struct {
+	Atoms     []int    `json:"atoms,omitempty"`
+	Fragments [][]int  `json:"fragments"`
+	Charges   []int    `json:"charges,omitempty"`
+	Bonds     [][2]int `json:"bonds"`
+}
+
+// DescriptionError error
+type DescriptionError struct {
+	Message string
+}
+
+func (err *DescriptionError) Error() string {
+	return "invalid fragment description: " + err.Message
+}
+
+// ReadFragmentDescription reads JSON fragment description
+func ReadFragmentDescription(reader io.Reader) (*FragmentDescription, error) {
+	var desc FragmentDescription
+	dec := json.NewDecoder(reader)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&desc); err != nil {
+		return nil, &DescriptionError{Message: err.Error()}
+	}
+	if len(desc.Fragments) == 0 {
+		return nil, &DescriptionError{Message: "no fragments"}
+	}
+	if desc.Charges != nil && len(desc.Charges) != len(desc.Fragments) {
+		return nil, &DescriptionError{Message: fmt.Sprintf("%d charges for %d fragments", len(desc.Charges), len(desc.Fragments))}
+	}
+	return &desc, nil
+}
+
+// Fragment builds &FRAGMENT. atoms overrides the atom order of the description if not nil.
+func (desc *FragmentDescription) Fragment(atoms []int) (*ajf.Fragment, error) {
+	if atoms == nil {
+		atoms = desc.Atoms
+	}
+	if atoms == nil {
+		for _, serials := range desc.Fragments {
+			atoms = append(atoms, serials...)
+		}
+		sort.Ints(atoms)
+	}
+	bonds := make([]ajf.Bond, len(desc.Bonds))
+	for i, b := range desc.Bonds {
+		bonds[i] = ajf.Bond{BDA: b[0], BAA: b[1]}
+	}
+	frag, err := ajf.NewFragment(atoms, desc.Fragments, desc.Charges, bonds)
+	if err != nil {
+		return nil, &DescriptionError{Message: err.Error()}
+	}
+	return frag, nil
+}
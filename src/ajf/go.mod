@@ -0,0 +1,5 @@
+module github.com/philopon/fmoe/ajf
+
+go 1.16
+
+require github.com/jessevdk/go-flags v1.5.0
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/philopon/fmoe/ajf/fragmentation"
+)
+
+// ligandAtoms returns the atoms of residues named name, or all atoms for empty name
+func ligandAtoms(m *fragmentation.Molecule, name string) []int {
+	var atoms []int
+	for _, r := range m.Residues {
+		if name == "" || r.Name == name {
+			atoms = append(atoms, r.Atoms...)
+		}
+	}
+	return atoms
+}
+
+// hybridFragments divides the fragments of the automatic fragmentation having the detached bonds, as the hybrid mode of the fragmentation panel.
+// ids are 1-origin fragment numbers of the automatic fragmentation for HYBRID_FRAG.
+func hybridFragments(m *fragmentation.Molecule, auto *fragmentation.Result, bonds []fragmentation.Bond, charges []int) (ids []int, result *fragmentation.Result) {
+	fragmentOf := auto.FragmentOf()
+	target := make(map[int]bool)
+	for _, b := range bonds {
+		target[fragmentOf[b.BDA]] = true
+		target[fragmentOf[b.BAA]] = true
+	}
+	var atoms []int
+	for f, fragment := range auto.Fragments {
+		if target[f] {
+			ids = append(ids, f+1)
+			atoms = append(atoms, fragment...)
+		}
+	}
+	fragments := m.PartitionAtoms(atoms, bonds)
+	result = &fragmentation.Result{Fragments: fragments, Bonds: bonds, Charges: make([]int, len(fragments))}
+	for f, fragment := range fragments {
+		for _, i := range fragment {
+			result.Charges[f] += charges[i]
+		}
+	}
+	return ids, result
+}
+
+// ligand divides a ligand at sp3 carbons, and writes the detached bonds as tab separated values:
+// BDA and BAA serials, BDA and BAA atoms, and the number of atoms of the fragments of BDA and BAA.
+// The serials are bda_id and baa_id of fmoe_fragmentation, or `ajf split -b BDA:BAA'.
+func ligand(opts *ligandOptions) (int, error) {
+	input := opts.Args.Input
+	if opts.Size <= 0 {
+		return optionParseFailed, fmt.Errorf("invalid size %d", opts.Size)
+	}
+	m, charges, bonds, code, err := autoFragmentation(input, opts.Format, opts.Charges, opts.Nucleic)
+	if err != nil {
+		return code, err
+	}
+	atoms := ligandAtoms(m, opts.Ligand)
+	if len(atoms) == 0 {
+		return invalid, fmt.Errorf("%s: %s is not found", input, opts.Ligand)
+	}
+
+	detached := m.LigandBonds(atoms, opts.Size)
+	fragments := m.PartitionAtoms(atoms, detached)
+	sizes := make([]string, len(fragments))
+	fragmentOf := make(map[int]int)
+	for f, fragment := range fragments {
+		sizes[f] = strconv.Itoa(len(fragment))
+		for _, i := range fragment {
+			fragmentOf[i] = f
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s: %d atoms are divided into %d fragments of %s atoms\n", input, len(atoms), len(fragments), strings.Join(sizes, ", "))
+
+	fmt.Println("#bda\tbaa\tbda_atom\tbaa_atom\tbda_atoms\tbaa_atoms")
+	for _, b := range detached {
+		bda, baa := m.Atom(b.BDA), m.Atom(b.BAA)
+		fmt.Printf("%d\t%d\t%s\t%s\t%d\t%d\n", bda.Serial, baa.Serial, bda.Label(), baa.Label(),
+			len(fragments[fragmentOf[b.BDA]]), len(fragments[fragmentOf[b.BAA]]))
+	}
+
+	if opts.Json != "" {
+		if len(detached) == 0 {
+			return invalid, fmt.Errorf("%s: no bonds to detach for the hybrid fragmentation", input)
+		}
+		ids, result := hybridFragments(m, m.Fragmentate(bonds, charges), detached, charges)
+		frag, err := result.Fragment(m)
+		if err != nil {
+			return parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		total := 0
+		for _, q := range charges {
+			total += q
+		}
+		hybrid := make([]string, len(ids))
+		for i, id := range ids {
+			hybrid[i] = strconv.Itoa(id)
+		}
+		data := map[string]interface{}{
+			"NUM_FRAGS":         frag.NF(),
+			"TOTAL_CHARGE":      total,
+			"ABINITMP_FRAGMENT": frag.Section(),
+			"HYBRID_FRAG":       strings.Join(hybrid, ","),
+			"HYBRID_NF":         frag.NF(),
+			"LIGAND_CHARGE":     "None",
+		}
+		if err := writeTemplateData(opts.Json, data); err != nil {
+			return ioError, err
+		}
+	}
+	return ok, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/philopon/fmoe/ajf/ajf"
+	"github.com/philopon/fmoe/ajf/basisset"
+	"github.com/philopon/fmoe/ajf/pdb"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+type validateOptions struct {
+	Pdb       string `short:"p" long:"pdb" description:"ReadGeom pdb file (ReadGeom of the input by default)" env:"PDB_PATH"`
+	BasisSets string `short:"b" long:"basisset" description:"basis set list (basisset/ajf.json next to the bin directory by default)" env:"AJF_BASISSET_PATH"`
+	Args      struct {
+		Input string `positional-arg-name:"input.ajf" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type fragmentOptions struct {
+	Input  string `short:"i" long:"input" description:"json fragment description (stdin by default)" env:"FRAGMENT_JSON_PATH"`
+	Output string `short:"o" long:"output" description:"output file of ABINITMP_FRAGMENT text (stdout by default)" env:"OUTPUT_PATH"`
+	Pdb    string `short:"p" long:"pdb" description:"ReadGeom pdb file giving the atom order" env:"PDB_PATH"`
+}
+
+type basisOptions struct {
+	Name     string   `short:"n" long:"name" description:"basis set name or alias, e.g. 6-31G(d) (BasisSet of the input or the default of the basis set list by default)" env:"BASIS_SET"`
+	Elements string   `short:"e" long:"elements" description:"count the elements instead of the input, e.g. \"C:2 H:6 O\""`
+	Pdb      string   `short:"p" long:"pdb" description:"ReadGeom pdb file of the ajf input (ReadGeom of the input by default)" env:"PDB_PATH"`
+	Data     string   `short:"d" long:"data" description:"basis functions of elements (basisset.json next to the bin directory by default)" env:"FMOE_BASISSET_PATH"`
+	Aliases  string   `short:"a" long:"aliases" description:"basis set list (basisset/ajf.json next to the bin directory by default)" env:"AJF_BASISSET_PATH"`
+	BSE      []string `short:"g" long:"bse" description:"additional basis set of the Basis Set Exchange json format"`
+	List     bool     `short:"l" long:"list" description:"list basis sets instead of counting"`
+	Args     struct {
+		Input string `positional-arg-name:"input.ajf|input.pdb"`
+	} `positional-args:"yes"`
+}
+
+type splitOptions struct {
+	Output      string   `short:"o" long:"output" description:"output file of ABINITMP_FRAGMENT text (stdout by default)" env:"OUTPUT_PATH"`
+	Table       string   `short:"t" long:"table" description:"output file of the fragment table"`
+	Json        string   `short:"j" long:"json" description:"output file of NUM_FRAGS, TOTAL_CHARGE and ABINITMP_FRAGMENT for fill_template --data"`
+	Charges     []string `short:"c" long:"charge" description:"total charge of residues by the name, e.g. LIG=-1"`
+	Nucleic     string   `short:"s" long:"nucleic" description:"fragmentation scheme of nucleic acids" choice:"+base" choice:"nucleotide" choice:"sugar-phosphate" default:"+base"`
+	Bonds       []string `short:"b" long:"bond" description:"additional detached bond by atom serials, e.g. 2345:2350 for BDA 2345 and BAA 2350"`
+	MergeMetals bool     `short:"M" long:"merge-metals" description:"merge metals with the fragments of their coordinating atoms"`
+	MetalRules  string   `short:"r" long:"metal-rules" description:"json rules of coordination distance, e.g. [{\"metal\": \"Zn\", \"elements\": [\"S\"], \"cutoff\": 2.7}]"`
+	MergeList   string   `short:"m" long:"merge-list" description:"output file of the merge list in the format of the fragmentation panel"`
+	Basis       string   `short:"n" long:"name" description:"basis set to count basis functions in the fragment table" env:"BASIS_SET"`
+	Data        string   `short:"d" long:"data" description:"basis functions of elements (basisset.json next to the bin directory by default)" env:"FMOE_BASISSET_PATH"`
+	Aliases     string   `short:"a" long:"aliases" description:"basis set list (basisset/ajf.json next to the bin directory by default)" env:"AJF_BASISSET_PATH"`
+	Args        struct {
+		Input string `positional-arg-name:"input.pdb" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type covalentOptions struct {
+	Ligand  string   `short:"l" long:"ligand" description:"residue name of the covalent ligand, e.g. LIG" required:"yes"`
+	Depth   int      `short:"k" long:"depth" description:"number of bonds from the covalent bond to search detachable bonds" default:"2"`
+	Charges []string `short:"c" long:"charge" description:"total charge of residues by the name, e.g. LIG=-1"`
+	Nucleic string   `short:"s" long:"nucleic" description:"fragmentation scheme of nucleic acids" choice:"+base" choice:"nucleotide" choice:"sugar-phosphate" default:"+base"`
+	Args    struct {
+		Input string `positional-arg-name:"input.pdb" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type ligandOptions struct {
+	Ligand  string   `short:"l" long:"ligand" description:"residue name of the ligand in the pdb file (all atoms by default)"`
+	Size    int      `short:"n" long:"size" description:"target number of atoms of the fragments, hydrogens included" default:"30"`
+	Format  string   `short:"f" long:"format" description:"format of the input (by the extension by default)" choice:"pdb" choice:"sdf" choice:"mol2"`
+	Json    string   `short:"j" long:"json" description:"output file of NUM_FRAGS, TOTAL_CHARGE, ABINITMP_FRAGMENT, HYBRID_FRAG, HYBRID_NF and LIGAND_CHARGE for fill_template --data of the hybrid fragmentation"`
+	Charges []string `short:"c" long:"charge" description:"total charge of residues by the name, e.g. LIG=-1"`
+	Nucleic string   `short:"s" long:"nucleic" description:"fragmentation scheme of nucleic acids" choice:"+base" choice:"nucleotide" choice:"sugar-phosphate" default:"+base"`
+	Args    struct {
+		Input string `positional-arg-name:"input.pdb|input.sdf|input.mol2" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+type options struct {
+	Validate validateOptions `command:"validate" description:"check an ajf file against its ReadGeom pdb"`
+	Fragment fragmentOptions `command:"fragment" description:"generate &FRAGMENT section from a json fragment description"`
+	Basis    basisOptions    `command:"basis" description:"count basis functions of the fragments of an ajf file, the residues of a pdb file or elements"`
+	Split    splitOptions    `command:"split" description:"divide a protonated pdb into fragments by the rules of the fragmentation panel"`
+	Covalent covalentOptions `command:"covalent" description:"propose detached bonds between a covalent ligand and the bonded residue"`
+	Ligand   ligandOptions   `command:"ligand" description:"divide a large ligand at single bonds of sp3 carbons"`
+}
+
+// exit codes
+const (
+	ok                int = 0
+	optionParseFailed     = 1 // invalid command line options
+	ioError               = 2 // failed to read the input files
+	parseError            = 3 // the ajf, pdb or json file is broken
+	invalid               = 4 // validation found problems
+)
+
+func readAjf(path string) (*ajf.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ajf.Parse(file)
+}
+
+func readPdb(path string) (*pdb.Structure, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return pdb.Read(file)
+}
+
+// readStructure reads a pdb, SDF or MOL2 file. The format is guessed by the extension if empty.
+func readStructure(path string, format string) (*pdb.Structure, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".sdf", ".sd", ".mol":
+			format = "sdf"
+		case ".mol2":
+			format = "mol2"
+		default:
+			format = "pdb"
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	switch format {
+	case "sdf":
+		return pdb.ReadSDF(file)
+	case "mol2":
+		return pdb.ReadMOL2(file)
+	}
+	return pdb.Read(file)
+}
+
+// defaultBasisSetPath is basisset/ajf.json of the repository, the executable is in bin
+func defaultBasisSetPath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), "..", "basisset", "ajf.json")
+}
+
+func validate(opts *validateOptions) (int, error) {
+	input := opts.Args.Input
+	file, err := readAjf(input)
+	if err != nil {
+		var uerr *ajf.UnterminatedGroup
+		if errors.As(err, &uerr) {
+			return parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		return ioError, err
+	}
+
+	pdbPath := opts.Pdb
+	if pdbPath == "" {
+		cntrl, err := file.Cntrl()
+		if err != nil {
+			return parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		if cntrl.ReadGeom == "" {
+			return optionParseFailed, fmt.Errorf("%s: ReadGeom is not specified, use `-p, --pdb'", input)
+		}
+		pdbPath = filepath.Join(filepath.Dir(input), cntrl.ReadGeom)
+	}
+	structure, err := readPdb(pdbPath)
+	if err != nil {
+		var perr *pdb.ParseError
+		if errors.As(err, &perr) {
+			return parseError, fmt.Errorf("%s: %w", pdbPath, err)
+		}
+		return ioError, err
+	}
+
+	var basisSets *basisset.Aliases
+	if opts.BasisSets != "" {
+		if basisSets, err = basisset.LoadAliases(opts.BasisSets); err != nil {
+			return ioError, err
+		}
+	} else if basisSets, err = basisset.LoadAliases(defaultBasisSetPath()); err != nil {
+		fmt.Fprintf(os.Stderr, "basis set list is not found, skip checking BasisSet: %s\n", err.Error())
+		basisSets = nil
+	}
+
+	diagnostics := Validate(file, structure, basisSets)
+	for _, d := range diagnostics {
+		if d.Line > 0 {
+			fmt.Printf("%s:%d: %s\n", input, d.Line, d.Message)
+		} else {
+			fmt.Printf("%s: %s\n", input, d.Message)
+		}
+	}
+	if len(diagnostics) > 0 {
+		return invalid, fmt.Errorf("%s: %d problems found", input, len(diagnostics))
+	}
+	return ok, nil
+}
+
+// fragment writes ABINITMP_FRAGMENT, followed by a newline
+func fragment(opts *fragmentOptions) (int, error) {
+	input := os.Stdin
+	if opts.Input != "" {
+		f, err := os.Open(opts.Input)
+		if err != nil {
+			return ioError, err
+		}
+		defer f.Close()
+		input = f
+	}
+	desc, err := ReadFragmentDescription(input)
+	if err != nil {
+		return parseError, err
+	}
+
+	var atoms []int
+	if opts.Pdb != "" {
+		structure, err := readPdb(opts.Pdb)
+		if err != nil {
+			var perr *pdb.ParseError
+			if errors.As(err, &perr) {
+				return parseError, fmt.Errorf("%s: %w", opts.Pdb, err)
+			}
+			return ioError, err
+		}
+		atoms = make([]int, len(structure.Atoms))
+		for i, a := range structure.Atoms {
+			atoms[i] = a.Serial
+		}
+	}
+	frag, err := desc.Fragment(atoms)
+	if err != nil {
+		return parseError, err
+	}
+
+	output := os.Stdout
+	if opts.Output != "" {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			return ioError, err
+		}
+		defer f.Close()
+		output = f
+	}
+	if _, err := fmt.Fprintln(output, frag.Section()); err != nil {
+		return ioError, err
+	}
+	return ok, nil
+}
+
+func mainProcess() (int, error) {
+	var opts options
+	parser := flags.NewParser(&opts, flags.Default&^flags.PrintErrors)
+	if _, err := parser.ParseArgs(os.Args[1:]); err != nil {
+		return optionParseFailed, err
+	}
+	switch parser.Active.Name {
+	case "validate":
+		return validate(&opts.Validate)
+	case "fragment":
+		return fragment(&opts.Fragment)
+	case "basis":
+		return basis(&opts.Basis)
+	case "split":
+		return split(&opts.Split)
+	case "covalent":
+		return covalent(&opts.Covalent)
+	case "ligand":
+		return ligand(&opts.Ligand)
+	}
+	return optionParseFailed, fmt.Errorf("unknown command: %s", parser.Active.Name)
+}
+
+func main() {
+	code, err := mainProcess()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+	}
+	os.Exit(code)
+}
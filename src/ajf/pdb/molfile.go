@@ -0,0 +1,245 @@
+package pdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Aromatic is the bond order of aromatic bonds, as the bond type 4 of SDF files
+const Aromatic = 4
+
+// BondOrder returns the bond order of SDF and MOL2 files by atom serials, or 0 if unknown
+func (s *Structure) BondOrder(a, b int) int {
+	if a > b {
+		a, b = b, a
+	}
+	return s.Orders[[2]int{a, b}]
+}
+
+// addBond adds a bond to CONECT records and bond orders
+func (s *Structure) addBond(a, b, order int) {
+	s.Conect[a] = append(s.Conect[a], b)
+	s.Conect[b] = append(s.Conect[b], a)
+	if a > b {
+		a, b = b, a
+	}
+	s.Orders[[2]int{a, b}] = order
+}
+
+// sdfCharges are the charge field of the atom block
+var sdfCharges = map[int]int{1: 3, 2: 2, 3: 1, 5: -1, 6: -2, 7: -3}
+
+// ligandName is the residue name of SDF files, which have no residues
+const ligandName = "UNL"
+
+// ReadSDF reads the first record of a SDF or MDL molfile of V2000.
+// Atoms are HETATM of a residue UNL 1 named by the element and the number, e.g. "C12", and their formal charges are given.
+func ReadSDF(reader io.Reader) (*Structure, error) {
+	s := Structure{Conect: make(map[int][]int), Orders: make(map[[2]int]int)}
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	next := func() (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", &ParseError{Line: line, Cause: io.ErrUnexpectedEOF}
+		}
+		line++
+		return scanner.Text(), nil
+	}
+	field := func(text string, begin, end int) string {
+		if begin >= len(text) {
+			return ""
+		}
+		if end > len(text) {
+			end = len(text)
+		}
+		return strings.TrimSpace(text[begin:end])
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := next(); err != nil {
+			return nil, err
+		}
+	}
+	counts, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(counts, "V3000") {
+		return nil, &ParseError{Line: line, Text: counts, Cause: errors.New("V3000 is not supported")}
+	}
+	na, err := strconv.Atoi(field(counts, 0, 3))
+	if err != nil {
+		return nil, &ParseError{Line: line, Text: counts, Cause: fmt.Errorf("invalid number of atoms: %w", err)}
+	}
+	nb, err := strconv.Atoi(field(counts, 3, 6))
+	if err != nil {
+		return nil, &ParseError{Line: line, Text: counts, Cause: fmt.Errorf("invalid number of bonds: %w", err)}
+	}
+
+	numbers := make(map[string]int)
+	for i := 0; i < na; i++ {
+		text, err := next()
+		if err != nil {
+			return nil, err
+		}
+		var xyz [3]float64
+		for k := range xyz {
+			if xyz[k], err = strconv.ParseFloat(field(text, 10*k, 10*k+10), 64); err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid coordinate: %w", err)}
+			}
+		}
+		element := normalizeElement(field(text, 31, 34))
+		if element == "" {
+			return nil, &ParseError{Line: line, Text: text, Cause: errors.New("empty element")}
+		}
+		charge := 0
+		if c := field(text, 36, 39); c != "" {
+			code, err := strconv.Atoi(c)
+			if err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid charge: %w", err)}
+			}
+			charge = sdfCharges[code]
+		}
+		numbers[element]++
+		s.Atoms = append(s.Atoms, Atom{
+			Index: i + 1, Serial: i + 1, Name: fmt.Sprintf("%s%d", element, numbers[element]),
+			ResName: ligandName, ResSeq: 1, X: xyz[0], Y: xyz[1], Z: xyz[2], Element: element,
+			Charge: charge, HasCharge: true, HetAtm: true, Line: line,
+		})
+	}
+	for i := 0; i < nb; i++ {
+		text, err := next()
+		if err != nil {
+			return nil, err
+		}
+		var values [3]int
+		for k := range values {
+			if values[k], err = strconv.Atoi(field(text, 3*k, 3*k+3)); err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid bond: %w", err)}
+			}
+		}
+		if values[0] < 1 || values[0] > na || values[1] < 1 || values[1] > na {
+			return nil, &ParseError{Line: line, Text: text, Cause: errors.New("atom of the bond is out of the atom block")}
+		}
+		s.addBond(values[0], values[1], values[2])
+	}
+
+	// M  CHG lines reset the charges of the atom block
+	reset := false
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.HasPrefix(text, "M  END") || strings.HasPrefix(text, "$$$$") {
+			break
+		}
+		if !strings.HasPrefix(text, "M  CHG") {
+			continue
+		}
+		if !reset {
+			for i := range s.Atoms {
+				s.Atoms[i].Charge = 0
+			}
+			reset = true
+		}
+		fields := strings.Fields(text[6:])
+		for k := 1; k+1 < len(fields); k += 2 {
+			a, err := strconv.Atoi(fields[k])
+			if err != nil || a < 1 || a > na {
+				return nil, &ParseError{Line: line, Text: text, Cause: errors.New("invalid atom of the charge")}
+			}
+			q, err := strconv.Atoi(fields[k+1])
+			if err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid charge: %w", err)}
+			}
+			s.Atoms[a-1].Charge = q
+		}
+	}
+	return &s, scanner.Err()
+}
+
+// mol2Orders are bond types of MOL2 files. Amide bonds are single bonds, as their carbons have double bonds.
+var mol2Orders = map[string]int{"1": 1, "2": 2, "3": 3, "ar": Aromatic, "am": 1, "du": 1, "un": 1, "nc": 0}
+
+// ReadMOL2 reads the first molecule of a Tripos MOL2 file.
+// Residues are the substructures, and formal charges are not given, as MOL2 files have partial charges.
+func ReadMOL2(reader io.Reader) (*Structure, error) {
+	s := Structure{Conect: make(map[int][]int), Orders: make(map[[2]int]int)}
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	section := ""
+	molecules := 0
+	serials := make(map[int]bool)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.HasPrefix(text, "@<TRIPOS>") {
+			section = strings.TrimPrefix(strings.TrimSpace(text), "@<TRIPOS>")
+			if section == "MOLECULE" {
+				molecules++
+				if molecules > 1 {
+					break
+				}
+			}
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch section {
+		case "ATOM":
+			if len(fields) < 6 {
+				return nil, &ParseError{Line: line, Text: text, Cause: errors.New("too short atom record")}
+			}
+			serial, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid atom id: %w", err)}
+			}
+			var xyz [3]float64
+			for k := range xyz {
+				if xyz[k], err = strconv.ParseFloat(fields[2+k], 64); err != nil {
+					return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid coordinate: %w", err)}
+				}
+			}
+			atom := Atom{
+				Index: len(s.Atoms) + 1, Serial: serial, Name: fields[1], ResName: ligandName, ResSeq: 1,
+				X: xyz[0], Y: xyz[1], Z: xyz[2], Element: normalizeElement(strings.SplitN(fields[5], ".", 2)[0]),
+				HetAtm: true, Line: line,
+			}
+			if len(fields) >= 7 {
+				if atom.ResSeq, err = strconv.Atoi(fields[6]); err != nil {
+					return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("invalid substructure id: %w", err)}
+				}
+			}
+			if len(fields) >= 8 {
+				atom.ResName = fields[7]
+			}
+			serials[serial] = true
+			s.Atoms = append(s.Atoms, atom)
+		case "BOND":
+			if len(fields) < 4 {
+				return nil, &ParseError{Line: line, Text: text, Cause: errors.New("too short bond record")}
+			}
+			a, errA := strconv.Atoi(fields[1])
+			b, errB := strconv.Atoi(fields[2])
+			if errA != nil || errB != nil || !serials[a] || !serials[b] {
+				return nil, &ParseError{Line: line, Text: text, Cause: errors.New("invalid atom of the bond")}
+			}
+			order, ok := mol2Orders[fields[3]]
+			if !ok {
+				return nil, &ParseError{Line: line, Text: text, Cause: fmt.Errorf("unknown bond type %s", fields[3])}
+			}
+			if order > 0 {
+				s.addBond(a, b, order)
+			}
+		}
+	}
+	return &s, scanner.Err()
+}
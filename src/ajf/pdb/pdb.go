@@ -0,0 +1,267 @@
+package pdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Atom is an ATOM or HETATM record
+type Atom struct {
+	// Index is 1-origin index in the file, which ABINIT-MP uses as atom number
+	Index   int
+	Serial  int
+	Name    string
+	ResName string
+	ChainID string
+	ResSeq  int
+	ICode   string
+	X, Y, Z float64
+	Element string
+	// Charge is formal charge of the columns 79-80, e.g. "1-", and HasCharge reports the columns are not blank
+	Charge    int
+	HasCharge bool
+	HetAtm    bool
+	// Line is 1-origin line number of the record
+	Line int
+	// Ter is the number of TER records before the atom, which separate chains of the same chain ID
+	Ter int
+}
+
+// Label is human readable atom label, e.g. "CA SER A1"
+func (a *Atom) Label() string {
+	return fmt.Sprintf("%s %s %s%d%s", a.Name, a.ResName, a.ChainID, a.ResSeq, a.ICode)
+}
+
+// Distance returns distance between atoms in angstrom
+func (a *Atom) Distance(b *Atom) float64 {
+	dx, dy, dz := a.X-b.X, a.Y-b.Y, a.Z-b.Z
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
+
+// Structure is atoms and CONECT records of a pdb file, or atoms and bonds of a SDF or MOL2 file
+type Structure struct {
+	Atoms []Atom
+	// Conect is bonded atom serials of CONECT records by atom serial
+	Conect map[int][]int
+	// Orders are bond orders of SDF and MOL2 files by the pair of atom serials in ascending order, nil for pdb files
+	Orders map[[2]int]int
+}
+
+// ParseError error
+type ParseError struct {
+	Line  int
+	Text  string
+	Cause error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v: %q", err.Line, err.Cause, err.Text)
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Cause
+}
+
+// Read reads ATOM, HETATM, TER and CONECT records. Only the first model is read.
+func Read(reader io.Reader) (*Structure, error) {
+	s := Structure{Conect: make(map[int][]int)}
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	ter := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "ATOM  ") || strings.HasPrefix(text, "HETATM"):
+			atom, err := parseAtom(text)
+			if err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: err}
+			}
+			atom.Index = len(s.Atoms) + 1
+			atom.Line = line
+			atom.Ter = ter
+			s.Atoms = append(s.Atoms, atom)
+		case strings.HasPrefix(text, "TER"):
+			ter++
+		case strings.HasPrefix(text, "CONECT"):
+			serials, err := parseConect(text)
+			if err != nil {
+				return nil, &ParseError{Line: line, Text: text, Cause: err}
+			}
+			s.Conect[serials[0]] = append(s.Conect[serials[0]], serials[1:]...)
+		case strings.HasPrefix(text, "ENDMDL"):
+			return &s, nil
+		}
+	}
+	return &s, scanner.Err()
+}
+
+func parseAtom(text string) (Atom, error) {
+	if len(text) < 54 {
+		return Atom{}, fmt.Errorf("too short atom record")
+	}
+	serial, err := strconv.Atoi(strings.TrimSpace(text[6:11]))
+	if err != nil {
+		return Atom{}, fmt.Errorf("invalid atom serial: %w", err)
+	}
+	resSeq, err := strconv.Atoi(strings.TrimSpace(text[22:26]))
+	if err != nil {
+		return Atom{}, fmt.Errorf("invalid residue sequence: %w", err)
+	}
+	var xyz [3]float64
+	for i := range xyz {
+		xyz[i], err = strconv.ParseFloat(strings.TrimSpace(text[30+8*i:38+8*i]), 64)
+		if err != nil {
+			return Atom{}, fmt.Errorf("invalid coordinate: %w", err)
+		}
+	}
+	atom := Atom{
+		Serial:  serial,
+		Name:    strings.TrimSpace(text[12:16]),
+		ResName: strings.TrimSpace(text[17:20]),
+		ChainID: strings.TrimSpace(text[21:22]),
+		ResSeq:  resSeq,
+		ICode:   strings.TrimSpace(text[26:27]),
+		X:       xyz[0],
+		Y:       xyz[1],
+		Z:       xyz[2],
+		HetAtm:  strings.HasPrefix(text, "HETATM"),
+	}
+	if len(text) >= 78 {
+		atom.Element = normalizeElement(text[76:78])
+	}
+	if atom.Element == "" {
+		atom.Element = elementFromName(text[12:16])
+	}
+	if len(text) >= 80 {
+		if field := strings.TrimSpace(text[78:80]); field != "" {
+			charge, err := parseCharge(field)
+			if err != nil {
+				return Atom{}, err
+			}
+			atom.Charge, atom.HasCharge = charge, true
+		}
+	}
+	return atom, nil
+}
+
+// parseCharge parses charge columns, e.g. "2+" or "1-"
+func parseCharge(field string) (int, error) {
+	sign := 1
+	switch {
+	case strings.HasSuffix(field, "-"):
+		sign = -1
+		field = strings.TrimSuffix(field, "-")
+	case strings.HasSuffix(field, "+"):
+		field = strings.TrimSuffix(field, "+")
+	}
+	if field == "" {
+		field = "1"
+	}
+	n, err := strconv.Atoi(field)
+	if err != nil {
+		return 0, fmt.Errorf("invalid charge: %w", err)
+	}
+	return sign * n, nil
+}
+
+func parseConect(text string) ([]int, error) {
+	var serials []int
+	for i := 6; i+5 <= len(text) && i < 31; i += 5 {
+		field := strings.TrimSpace(text[i : i+5])
+		if field == "" {
+			continue
+		}
+		serial, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid atom serial: %w", err)
+		}
+		serials = append(serials, serial)
+	}
+	if len(serials) == 0 {
+		return nil, fmt.Errorf("empty CONECT record")
+	}
+	return serials, nil
+}
+
+func normalizeElement(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+}
+
+// elementFromName guesses element from the atom name columns.
+// Element symbol is right justified in the first two columns, e.g. " CA " is carbon and "CA  " is calcium.
+func elementFromName(name string) string {
+	if len(name) < 2 {
+		return normalizeElement(name)
+	}
+	first := rune(name[0])
+	if first == ' ' || unicode.IsDigit(first) {
+		return normalizeElement(strings.TrimFunc(name[1:2], unicode.IsDigit))
+	}
+	// hydrogens of 4 letters names, e.g. "HD21"
+	if first == 'H' && len(strings.TrimSpace(name)) == 4 {
+		return "H"
+	}
+	return normalizeElement(name[:2])
+}
+
+// Bonded reports whether atoms are bonded.
+// CONECT records are used if any, otherwise bonds are inferred by covalent radii.
+func (s *Structure) Bonded(a, b *Atom) bool {
+	if len(s.Conect) > 0 {
+		for _, serial := range s.Conect[a.Serial] {
+			if serial == b.Serial {
+				return true
+			}
+		}
+		for _, serial := range s.Conect[b.Serial] {
+			if serial == a.Serial {
+				return true
+			}
+		}
+		if a.HetAtm || b.HetAtm {
+			return false
+		}
+	}
+	return a.Distance(b) <= CovalentRadius(a.Element)+CovalentRadius(b.Element)+bondTolerance
+}
+
+const bondTolerance = 0.45
+
+var covalentRadii = map[string]float64{
+	"H": 0.31, "B": 0.84, "C": 0.76, "N": 0.71, "O": 0.66, "F": 0.57,
+	"Na": 1.66, "Mg": 1.41, "Si": 1.11, "P": 1.07, "S": 1.05, "Cl": 1.02,
+	"K": 2.03, "Ca": 1.76, "Mn": 1.39, "Fe": 1.32, "Co": 1.26, "Ni": 1.24,
+	"Cu": 1.32, "Zn": 1.22, "Se": 1.20, "Br": 1.20, "I": 1.39,
+}
+
+// CovalentRadius returns covalent radius of the element in angstrom
+func CovalentRadius(element string) float64 {
+	if r, ok := covalentRadii[element]; ok {
+		return r
+	}
+	return 1.5
+}
+
+var atomicNumbers = map[string]int{
+	"H": 1, "He": 2, "Li": 3, "Be": 4, "B": 5, "C": 6, "N": 7, "O": 8, "F": 9, "Ne": 10,
+	"Na": 11, "Mg": 12, "Al": 13, "Si": 14, "P": 15, "S": 16, "Cl": 17, "Ar": 18,
+	"K": 19, "Ca": 20, "Sc": 21, "Ti": 22, "V": 23, "Cr": 24, "Mn": 25, "Fe": 26, "Co": 27, "Ni": 28,
+	"Cu": 29, "Zn": 30, "Ga": 31, "Ge": 32, "As": 33, "Se": 34, "Br": 35, "Kr": 36,
+	"Rb": 37, "Sr": 38, "Y": 39, "Zr": 40, "Nb": 41, "Mo": 42, "Tc": 43, "Ru": 44, "Rh": 45, "Pd": 46,
+	"Ag": 47, "Cd": 48, "In": 49, "Sn": 50, "Sb": 51, "Te": 52, "I": 53, "Xe": 54,
+}
+
+// AtomicNumber returns atomic number of the element, or 0 if unknown
+func AtomicNumber(element string) int {
+	return atomicNumbers[element]
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/philopon/fmoe/ajf/basisset"
+	"github.com/philopon/fmoe/ajf/fragmentation"
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+// parseChargeOverrides parses NAME=CHARGE, e.g. LIG=-1
+func parseChargeOverrides(values []string) (map[string]int, error) {
+	overrides := make(map[string]int, len(values))
+	for _, v := range values {
+		i := strings.IndexRune(v, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid charge %q, NAME=CHARGE is expected", v)
+		}
+		q, err := strconv.Atoi(strings.TrimPrefix(v[i+1:], "+"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid charge %q: %w", v, err)
+		}
+		overrides[strings.TrimSpace(v[:i])] = q
+	}
+	return overrides, nil
+}
+
+// writeFragmentTable writes the fragment table of the fragmentation panel as tab separated values:
+// fragment number, residue, number of atoms, number of basis functions, number of BDAs, number of BAAs and charge.
+// The number of basis functions is counted with the BAAs of the fragment, and empty without the basis set
+// or for elements the basis set does not cover.
+func writeFragmentTable(path string, m *fragmentation.Molecule, result *fragmentation.Result, table *basisset.Table, basisName string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fragmentOf := result.FragmentOf()
+	nD, nA := result.Electrons()
+	attached := make([][]*pdb.Atom, len(result.Fragments))
+	for _, b := range result.Bonds {
+		attached[fragmentOf[b.BAA]] = append(attached[fragmentOf[b.BAA]], m.Atom(b.BAA))
+	}
+	for i, indices := range result.Fragments {
+		atoms := make([]*pdb.Atom, len(indices))
+		for k, j := range indices {
+			atoms[k] = m.Atom(j)
+		}
+		functions := ""
+		if table != nil {
+			// fragments with uncovered elements are left empty, as their count is not the number of basis functions
+			if n, err := table.Count(basisName, countElements(atoms, attached[i])); err == nil {
+				functions = strconv.Itoa(n)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: fragment %d: %v\n", path, i+1, err)
+			}
+		}
+		if _, err := fmt.Fprintf(f, "%d\t%s\t%d\t%s\t%d\t%d\t%d\n", i+1, residueLabel(atoms), len(atoms), functions, nD[i], nA[i], result.Charges[i]+nD[i]-nA[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTemplateData writes template variables, e.g. NUM_FRAGS, TOTAL_CHARGE and ABINITMP_FRAGMENT, as json for fill_template
+func writeTemplateData(path string, data map[string]interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(data)
+}
+
+// loadMetalRules reads rules of metal coordination, or returns the default rules for empty path
+func loadMetalRules(path string) ([]fragmentation.MetalRule, error) {
+	if path == "" {
+		return fragmentation.DefaultMetalRules, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := fragmentation.ReadMetalRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// reportCoordinations prints coordinating atoms of each metal, e.g. "ZN A301: SG CYS A96 (2.31)"
+func reportCoordinations(name string, m *fragmentation.Molecule, coordinations []fragmentation.Coordination) {
+	for _, c := range coordinations {
+		metal := m.Atom(c.Metal)
+		ligands := make([]string, len(c.Ligands))
+		for i, l := range c.Ligands {
+			a := m.Atom(l)
+			ligands[i] = fmt.Sprintf("%s (%.2f)", a.Label(), metal.Distance(a))
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s: %s\n", name, metal.Label(), strings.Join(ligands, ", "))
+	}
+}
+
+// writeMergeList writes the merge list as FormatMergeList
+func writeMergeList(path string, list [][]int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, line := range fragmentation.FormatMergeList(list) {
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBonds parses BDA:BAA by atom serials, e.g. 2345:2350
+func parseBonds(values []string, m *fragmentation.Molecule) ([]fragmentation.Bond, error) {
+	positions := make(map[int]int, len(m.Structure.Atoms))
+	for i, a := range m.Structure.Atoms {
+		positions[a.Serial] = i
+	}
+	var bonds []fragmentation.Bond
+	for _, v := range values {
+		fields := strings.Split(v, ":")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid bond %q, BDA:BAA is expected", v)
+		}
+		var atoms [2]int
+		for k, field := range fields {
+			serial, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return nil, fmt.Errorf("invalid bond %q: %w", v, err)
+			}
+			i, ok := positions[serial]
+			if !ok {
+				return nil, fmt.Errorf("invalid bond %q: atom %d is not found", v, serial)
+			}
+			atoms[k] = i
+		}
+		if !m.IsBonded(atoms[0], atoms[1]) {
+			return nil, fmt.Errorf("invalid bond %q: atoms are not bonded", v)
+		}
+		bonds = append(bonds, fragmentation.Bond{BDA: atoms[0], BAA: atoms[1]})
+	}
+	return bonds, nil
+}
+
+// autoFragmentation reads the structure of the format, and returns the molecule, formal charges of the atoms and detached bonds of the automatic fragmentation
+func autoFragmentation(input string, format string, chargeValues []string, nucleic string) (*fragmentation.Molecule, []int, []fragmentation.Bond, int, error) {
+	structure, err := readStructure(input, format)
+	if err != nil {
+		var perr *pdb.ParseError
+		if errors.As(err, &perr) {
+			return nil, nil, nil, parseError, fmt.Errorf("%s: %w", input, err)
+		}
+		return nil, nil, nil, ioError, err
+	}
+	overrides, err := parseChargeOverrides(chargeValues)
+	if err != nil {
+		return nil, nil, nil, optionParseFailed, err
+	}
+
+	scheme, err := fragmentation.ParseNucleicScheme(nucleic)
+	if err != nil {
+		return nil, nil, nil, optionParseFailed, err
+	}
+
+	m := fragmentation.NewMolecule(structure)
+	charges, warnings, err := m.FormalCharges(overrides)
+	if err != nil {
+		return nil, nil, nil, invalid, fmt.Errorf("%s: %w", input, err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", input, w)
+	}
+	return m, charges, m.AutoBonds(scheme), ok, nil
+}
+
+// split divides a pdb into fragments, and writes &FRAGMENT
+func split(opts *splitOptions) (int, error) {
+	input := opts.Args.Input
+	m, charges, bonds, code, err := autoFragmentation(input, "pdb", opts.Charges, opts.Nucleic)
+	if err != nil {
+		return code, err
+	}
+	additional, err := parseBonds(opts.Bonds, m)
+	if err != nil {
+		return optionParseFailed, err
+	}
+	result := m.Fragmentate(append(bonds, additional...), charges)
+
+	if opts.MergeMetals {
+		rules, err := loadMetalRules(opts.MetalRules)
+		if err != nil {
+			return ioError, err
+		}
+		coordinations := m.Coordinations(rules)
+		reportCoordinations(input, m, coordinations)
+		list := result.MergeList(coordinations)
+		if opts.MergeList != "" {
+			if err := writeMergeList(opts.MergeList, list); err != nil {
+				return ioError, err
+			}
+		}
+		result = result.Merge(list)
+	}
+	frag, err := result.Fragment(m)
+	if err != nil {
+		return parseError, fmt.Errorf("%s: %w", input, err)
+	}
+
+	if opts.Table != "" {
+		var table *basisset.Table
+		if opts.Basis != "" {
+			if table, err = loadBasisTable(opts.Data, opts.Aliases, nil); err != nil {
+				return ioError, err
+			}
+			if _, err := table.Resolve(opts.Basis); err != nil {
+				return invalid, err
+			}
+		}
+		if err := writeFragmentTable(opts.Table, m, result, table, opts.Basis); err != nil {
+			return ioError, err
+		}
+	}
+	if opts.Json != "" {
+		total := 0
+		for _, q := range charges {
+			total += q
+		}
+		data := map[string]interface{}{"NUM_FRAGS": frag.NF(), "TOTAL_CHARGE": total, "ABINITMP_FRAGMENT": frag.Section()}
+		if err := writeTemplateData(opts.Json, data); err != nil {
+			return ioError, err
+		}
+	}
+
+	output := os.Stdout
+	if opts.Output != "" {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			return ioError, err
+		}
+		defer f.Close()
+		output = f
+	}
+	if _, err := fmt.Fprintln(output, frag.Section()); err != nil {
+		return ioError, err
+	}
+	return ok, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/philopon/fmoe/ajf/ajf"
+	"github.com/philopon/fmoe/ajf/basisset"
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+// Diagnostic is a problem found in an ajf file
+type Diagnostic struct {
+	// Line is 1-origin line number in the ajf file, or 0 if the problem is not related to a line
+	Line    int
+	Message string
+}
+
+type validator struct {
+	file        *ajf.File
+	structure   *pdb.Structure
+	basisSets   *basisset.Aliases
+	diagnostics []Diagnostic
+}
+
+func (v *validator) report(line int, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks ajf file against the ReadGeom structure.
+// basisSets may be nil to skip the basis set check.
+func Validate(file *ajf.File, structure *pdb.Structure, basisSets *basisset.Aliases) []Diagnostic {
+	v := validator{file: file, structure: structure, basisSets: basisSets}
+	v.validateBasisSet()
+	if frag := v.validateNF(); frag != nil {
+		v.validateAtoms(frag)
+		v.validateBonds(frag)
+		v.validateCharges(frag)
+	}
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		return v.diagnostics[i].Line < v.diagnostics[j].Line
+	})
+	return v.diagnostics
+}
+
+func (v *validator) validateBasisSet() {
+	if v.basisSets == nil {
+		return
+	}
+	g := v.file.Group("BASIS")
+	if g == nil {
+		v.report(0, "&BASIS group is missing")
+		return
+	}
+	value, ok := g.Get("BasisSet")
+	if !ok {
+		v.report(g.Header.Num, "BasisSet is not specified")
+		return
+	}
+	name := value.String()
+	switch key, found := v.basisSets.Lookup(name); {
+	case !found:
+		v.report(g.LineOf("BasisSet"), "unknown basis set %q", name)
+	case key == "":
+		v.report(g.LineOf("BasisSet"), "basis set %q is not supported by ABINIT-MP", name)
+	}
+}
+
+// validateNF checks NF against the atom-count line of &FRAGMENT, and parses &FRAGMENT
+func (v *validator) validateNF() *ajf.Fragment {
+	fmo, err := v.file.FmoCntrl()
+	if err != nil {
+		v.reportError(err)
+		return nil
+	}
+	g := v.file.Group("FRAGMENT")
+	if g == nil || len(g.Lines) == 0 {
+		if !strings.EqualFold(fmo.AutoFrag, "ON") {
+			v.report(0, "&FRAGMENT group is missing while AutoFrag is not 'ON'")
+		}
+		return nil
+	}
+	nfLine := 0
+	if fmoGroup := v.file.Group("FMOCNTRL"); fmoGroup != nil {
+		nfLine = fmoGroup.LineOf("NF")
+	}
+	if fmo.NF <= 0 {
+		v.report(nfLine, "NF must be positive, but %d", fmo.NF)
+		return nil
+	}
+
+	// the atom-count line is wrapped by 10, so NF values fill ceil(NF/10) lines
+	nlines := (fmo.NF + 9) / 10
+	for i := 0; i < nlines; i++ {
+		expected := 10
+		if i == nlines-1 {
+			expected = fmo.NF - 10*i
+		}
+		if i >= len(g.Lines) {
+			v.report(nfLine, "NF=%d, but the atom-count line of &FRAGMENT has only %d lines", fmo.NF, i)
+			return nil
+		}
+		if n := len(strings.Fields(strings.SplitN(g.Lines[i].Text, "!", 2)[0])); n != expected {
+			v.report(nfLine, "NF=%d, but the atom-count line of &FRAGMENT has %d values at line %d, expected %d", fmo.NF, n, g.Lines[i].Num, expected)
+			return nil
+		}
+	}
+
+	frag, err := ajf.ParseFragment(g, fmo.NF)
+	if err != nil {
+		v.reportError(err)
+		return nil
+	}
+	return frag
+}
+
+// validateAtoms checks every atom appears in exactly one fragment
+func (v *validator) validateAtoms(frag *ajf.Fragment) {
+	natoms := len(v.structure.Atoms)
+	fragmentOf := make([]int, natoms+1)
+	for i, atoms := range frag.Atoms {
+		line := frag.Positions.Atoms[i]
+		for _, a := range atoms {
+			switch {
+			case a < 1 || a > natoms:
+				v.report(line, "atom %d of fragment %d is out of range [1, %d]", a, i+1, natoms)
+			case fragmentOf[a] == i+1:
+				v.report(line, "atom %d (%s) appears twice in fragment %d", a, v.label(a), i+1)
+			case fragmentOf[a] != 0:
+				v.report(line, "atom %d (%s) is in fragments %d and %d", a, v.label(a), fragmentOf[a], i+1)
+			default:
+				fragmentOf[a] = i + 1
+			}
+		}
+	}
+
+	var missing []string
+	for a := 1; a <= natoms; a++ {
+		if fragmentOf[a] == 0 {
+			missing = append(missing, fmt.Sprintf("%d (%s)", a, v.label(a)))
+		}
+	}
+	if len(missing) > 0 {
+		const shown = 5
+		message := strings.Join(missing, ", ")
+		if len(missing) > shown {
+			message = strings.Join(missing[:shown], ", ") + fmt.Sprintf(", ... and %d more", len(missing)-shown)
+		}
+		v.report(v.file.Group("FRAGMENT").Header.Num, "%d atoms are not in any fragment: %s", len(missing), message)
+	}
+}
+
+// validateBonds checks BDA and BAA are bonded atoms in different fragments
+func (v *validator) validateBonds(frag *ajf.Fragment) {
+	natoms := len(v.structure.Atoms)
+	fragmentOf := frag.FragmentOf()
+	for i, b := range frag.Bonds {
+		line := frag.Positions.Bonds[i]
+		if b.BDA < 1 || b.BDA > natoms || b.BAA < 1 || b.BAA > natoms {
+			v.report(line, "BDA %d or BAA %d is out of range [1, %d]", b.BDA, b.BAA, natoms)
+			continue
+		}
+		bda := &v.structure.Atoms[b.BDA-1]
+		baa := &v.structure.Atoms[b.BAA-1]
+		if !v.structure.Bonded(bda, baa) {
+			v.report(line, "BDA %d (%s) and BAA %d (%s) are not bonded (%.2f A)", b.BDA, bda.Label(), b.BAA, baa.Label(), bda.Distance(baa))
+		}
+		if fragmentOf[b.BDA] != 0 && fragmentOf[b.BDA] == fragmentOf[b.BAA] {
+			v.report(line, "BDA %d (%s) and BAA %d (%s) are in the same fragment %d", b.BDA, bda.Label(), b.BAA, baa.Label(), fragmentOf[b.BDA])
+		}
+	}
+}
+
+// validateCharges checks sum of fragment charges equals Charge of &CNTRL
+func (v *validator) validateCharges(frag *ajf.Fragment) {
+	cntrl, err := v.file.Cntrl()
+	if err != nil {
+		v.reportError(err)
+		return
+	}
+	sum := 0
+	for _, c := range frag.Charges {
+		sum += c
+	}
+	if sum != cntrl.Charge {
+		line := 0
+		if g := v.file.Group("CNTRL"); g != nil {
+			line = g.LineOf("Charge")
+		}
+		v.report(line, "Charge=%d, but sum of fragment charges (line %d) is %d", cntrl.Charge, frag.Positions.Charges[0], sum)
+	}
+}
+
+func (v *validator) reportError(err error) {
+	switch e := err.(type) {
+	case *ajf.DecodeError:
+		v.report(e.Line, "&%s %s: %v", e.Group, e.Key, e.Err)
+	case *ajf.FragmentError:
+		v.report(e.Line, "%s", e.Message)
+	default:
+		v.report(0, "%v", err)
+	}
+}
+
+func (v *validator) label(index int) string {
+	if index < 1 || index > len(v.structure.Atoms) {
+		return "?"
+	}
+	return v.structure.Atoms[index-1].Label()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/philopon/fmoe/ajf/ajf"
+)
+
+// ErrNoFragmentGroup indicates the ajf has no fragmentation to import
+var ErrNoFragmentGroup = errors.New("&FRAGMENT group is not found or empty. Is it an input with AutoFrag='OFF'?")
+
+// ParseAjfFragment reads fragmentation of &FRAGMENT group of an ajf file.
+// Electrons and residues are not in ajf, so they are left zero until labelFragments fills them by the pdb.
+func ParseAjfFragment(reader io.Reader) (*AutoFrag, error) {
+	file, err := ajf.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+	frag, err := file.Fragment()
+	if err != nil {
+		return nil, err
+	}
+	if frag == nil || frag.NF() == 0 {
+		return nil, ErrNoFragmentGroup
+	}
+
+	var result AutoFrag
+	for _, b := range frag.Bonds {
+		result.BDA = append(result.BDA, b.BDA)
+		result.BAA = append(result.BAA, b.BAA)
+	}
+	result.Fragments = make([]Fragment, frag.NF())
+	for i := range result.Fragments {
+		result.Fragments[i] = Fragment{
+			Index:  i + 1,
+			Atoms:  frag.Atoms[i],
+			Charge: frag.Charges[i],
+		}
+	}
+	return &result, nil
+}
+
+// isAjfParseError checks error is caused by broken ajf file
+func isAjfParseError(err error) bool {
+	var uerr *ajf.UnterminatedGroup
+	var derr *ajf.DecodeError
+	var ferr *ajf.FragmentError
+	return errors.Is(err, ErrNoFragmentGroup) || errors.As(err, &uerr) || errors.As(err, &derr) || errors.As(err, &ferr)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+// Fragment is a fragment assigned by ABINIT-MP AutoFrag
+type Fragment struct {
+	Index     int
+	Atoms     []int
+	Charge    int
+	Electrons int
+	Residue   string
+}
+
+// AutoFrag is result of ABINIT-MP AutoFrag
+type AutoFrag struct {
+	BDA       []int
+	BAA       []int
+	Fragments []Fragment
+}
+
+type AutoFragParser struct {
+	scanner   *bufio.Scanner
+	result    AutoFrag
+	fragments map[int]*Fragment
+	line      int
+	text      string
+}
+
+// ErrNilPointerReceiver indicate nil pointer receiver error
+var ErrNilPointerReceiver = errors.New("nil pointer receiver")
+
+// ParseError is error at a line of the autofrag log
+type ParseError struct {
+	Line  int
+	Text  string
+	Cause error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v: %q", err.Line, err.Cause, err.Text)
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Cause
+}
+
+// TableNotFound error indicates the log has no fragment table
+type TableNotFound struct {
+	Header string
+	Lines  int
+}
+
+func (err *TableNotFound) Error() string {
+	return fmt.Sprintf("fragment table header %q was not found in %d lines. "+
+		"Is it a log of ABINIT-MP run with AutoFrag='ON'?", err.Header, err.Lines)
+}
+
+// IsTableNotFound checks error is, or wraps, TableNotFound or not
+func IsTableNotFound(e error) bool {
+	var terr *TableNotFound
+	return errors.As(e, &terr)
+}
+
+// ErrShortLine indicates a line is shorter than the table columns
+var ErrShortLine = errors.New("line is too short")
+
+func (p *AutoFragParser) errorf(cause error) error {
+	return &ParseError{Line: p.line, Text: p.text, Cause: cause}
+}
+
+const bondTableHeader = "Frag.   Bonded Atom  Proj."
+
+const fragmentTableHeader = "Frag.   Elec.   ATOM"
+
+func (p *AutoFragParser) scan() (string, error) {
+	if p == nil {
+		return "", ErrNilPointerReceiver
+	}
+	r := p.scanner.Scan()
+	if r {
+		p.line++
+		p.text = p.scanner.Text()
+		return p.text, nil
+	}
+	if err := p.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (p *AutoFragParser) fragment(index int) *Fragment {
+	if f, ok := p.fragments[index]; ok {
+		return f
+	}
+	f := &Fragment{Index: index}
+	p.fragments[index] = f
+	return f
+}
+
+// parseBondTable parses BDA and BAA after "Frag.   Bonded Atom  Proj." header.
+func (p *AutoFragParser) parseBondTable() (string, error) {
+	for {
+		line, err := p.scan()
+		if err != nil {
+			return "", err
+		}
+		if len(line) < 13 {
+			return line, nil
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(line[0:13])); err != nil {
+			return line, nil
+		} else {
+			if len(line) < 27 {
+				return "", p.errorf(ErrShortLine)
+			}
+			if v, err := strconv.Atoi(strings.TrimSpace(line[14:21])); err == nil {
+				p.result.BDA = append(p.result.BDA, v)
+			} else {
+				return "", p.errorf(fmt.Errorf("invalid BDA: %w", err))
+			}
+			if v, err := strconv.Atoi(strings.TrimSpace(line[22:27])); err == nil {
+				p.result.BAA = append(p.result.BAA, v)
+			} else {
+				return "", p.errorf(fmt.Errorf("invalid BAA: %w", err))
+			}
+		}
+	}
+}
+
+// parseFragmentTable parses the fragment table after "Frag.   Elec.   ATOM" header, e.g.
+//
+//	Frag.   Elec.   ATOM
+//	    1     40      1     2     3     4     5     6     7     8     9    10
+//	                 11    12    13
+//
+// ATOM column may continue to following lines, which are indented deeper than Elec. column.
+// Charges and residues are not in the table, see labelFragments.
+func (p *AutoFragParser) parseFragmentTable(header string) (string, error) {
+	indent := strings.Index(header, "Elec.")
+
+	var current *Fragment
+	for {
+		line, err := p.scan()
+		if err != nil {
+			return "", err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return line, nil
+		}
+
+		if current != nil && len(line)-len(strings.TrimLeft(line, " ")) >= indent {
+			atoms, err := atoi(fields)
+			if err != nil {
+				return line, nil
+			}
+			current.Atoms = append(current.Atoms, atoms...)
+			continue
+		}
+
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return line, nil
+		}
+		if len(fields) < 3 {
+			return "", p.errorf(ErrShortLine)
+		}
+		current = p.fragment(index)
+		if current.Electrons, err = strconv.Atoi(fields[1]); err != nil {
+			return "", p.errorf(fmt.Errorf("invalid number of electrons: %w", err))
+		}
+		if current.Atoms, err = atoi(fields[2:]); err != nil {
+			return "", p.errorf(fmt.Errorf("invalid atom: %w", err))
+		}
+	}
+}
+
+func atoi(fields []string) ([]int, error) {
+	vals := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// isFragmentTableHeader reports whether the line is fragmentTableHeader, ignoring the widths of the spaces.
+// The header of bond tables also starts with "Frag.", and it is not a fragment table.
+func isFragmentTableHeader(line string) bool {
+	return strings.Join(strings.Fields(line), " ") == strings.Join(strings.Fields(fragmentTableHeader), " ")
+}
+
+func (p *AutoFragParser) parse() (*AutoFrag, error) {
+	p.fragments = make(map[int]*Fragment)
+	foundBondTable := false
+
+	line, err := p.scan()
+	for err == nil {
+		if strings.Contains(line, bondTableHeader) && !foundBondTable {
+			foundBondTable = true
+			line, err = p.parseBondTable()
+		} else if isFragmentTableHeader(line) {
+			line, err = p.parseFragmentTable(line)
+		} else {
+			line, err = p.scan()
+		}
+	}
+	if err != io.EOF {
+		return &p.result, err
+	}
+
+	indices := make([]int, 0, len(p.fragments))
+	for i := range p.fragments {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		p.result.Fragments = append(p.result.Fragments, *p.fragments[i])
+	}
+
+	// logs without detached bonds, e.g. of ligands, have no bond table
+	if len(p.fragments) == 0 {
+		return &p.result, &TableNotFound{Header: fragmentTableHeader, Lines: p.line}
+	}
+	return &p.result, nil
+}
+
+// residueLabel is the most frequent residue of the atoms, e.g. "SER1"
+func residueLabel(atoms []*pdb.Atom) string {
+	freq := make(map[string]int)
+	label := ""
+	for _, a := range atoms {
+		key := fmt.Sprintf("%s%d", a.ResName, a.ResSeq)
+		freq[key]++
+		if freq[key] > freq[label] {
+			label = key
+		}
+	}
+	return label
+}
+
+// labelFragments fills residues of the fragments by the ReadGeom pdb atoms, whose 1-origin indices are the atom numbers.
+// The nuclear charge of a fragment is that of the atoms, less one for each BDA and more one for each BAA,
+// and the charge is the nuclear charge minus the electrons.
+// Charges are filled for AutoFrag logs, which have electrons, and electrons are filled for ajf inputs, which have charges.
+func labelFragments(autofrag *AutoFrag, structure *pdb.Structure, fromAjf bool) error {
+	shift := make(map[int]int)
+	for i := range autofrag.BDA {
+		shift[autofrag.BDA[i]]--
+		shift[autofrag.BAA[i]]++
+	}
+	for k := range autofrag.Fragments {
+		f := &autofrag.Fragments[k]
+		atoms := make([]*pdb.Atom, len(f.Atoms))
+		nuclear := 0
+		for n, i := range f.Atoms {
+			if i < 1 || i > len(structure.Atoms) {
+				return fmt.Errorf("atom %d of fragment %d is out of the %d atoms of the pdb", i, f.Index, len(structure.Atoms))
+			}
+			atoms[n] = &structure.Atoms[i-1]
+			z := pdb.AtomicNumber(atoms[n].Element)
+			if z == 0 {
+				return fmt.Errorf("unknown element %q of atom %d (%s)", atoms[n].Element, i, atoms[n].Label())
+			}
+			nuclear += z + shift[i]
+		}
+		f.Residue = residueLabel(atoms)
+		if fromAjf {
+			f.Electrons = nuclear - f.Charge
+		} else {
+			f.Charge = nuclear - f.Electrons
+		}
+	}
+	return nil
+}
+
+func ParseAutoFrag(reader io.Reader) (*AutoFrag, error) {
+	parser := AutoFragParser{scanner: bufio.NewScanner(reader)}
+	return parser.parse()
+}
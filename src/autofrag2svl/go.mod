@@ -2,4 +2,9 @@ module github.com/kyusque/autofrag2svl
 
 go 1.16
 
-require github.com/jessevdk/go-flags v1.5.0
+require (
+	github.com/jessevdk/go-flags v1.5.0
+	github.com/philopon/fmoe/ajf v0.0.0
+)
+
+replace github.com/philopon/fmoe/ajf => ../ajf
@@ -7,15 +7,19 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
+	"path/filepath"
 	"strings"
 
 	flags "github.com/jessevdk/go-flags"
+	"github.com/philopon/fmoe/ajf/pdb"
 )
 
 type options struct {
-	AutoFrag string `short:"i" long:"input" description:"abinitmp autofrag log" env:"AUTOFRAG_PATH"`
-	SvlBin   string `short:"o" long:"output" description:"auto frag svl binary data" env:"SVLBIN_PATH"`
+	AutoFrag string `short:"i" long:"input" description:"abinitmp autofrag log or ajf input" env:"AUTOFRAG_PATH"`
+	Input    string `short:"t" long:"input-format" description:"input format (ajf if the extension is .ajf on auto)" choice:"auto" choice:"log" choice:"ajf" default:"auto" env:"AUTOFRAG_FORMAT"`
+	SvlBin   string `short:"o" long:"output" description:"output file (moe binary by default)" env:"SVLBIN_PATH"`
+	Format   string `short:"f" long:"format" description:"output format" choice:"svl" choice:"json" choice:"tsv" default:"svl"`
+	Pdb      string `short:"p" long:"pdb" description:"ReadGeom pdb file to label atoms, and fill charges of logs, electrons of ajf inputs and residues" env:"PDB_PATH"`
 }
 
 type SVLWriter struct {
@@ -34,6 +38,24 @@ func (w *SVLWriter) WriteSize(v uint32) error {
 	return binary.Write(w.writer, binary.BigEndian, v)
 }
 
+func (w *SVLWriter) WriteToken(tokens []string) error {
+	if err := w.writer.WriteByte(4); err != nil {
+		return err
+	}
+	if err := w.WriteSize(uint32(len(tokens))); err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := w.WriteSize(uint32(len(token))); err != nil {
+			return err
+		}
+		if _, err := w.writer.WriteString(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *SVLWriter) WriteInt(vals []int) error {
 	if err := w.writer.WriteByte(2); err != nil {
 		return err
@@ -49,88 +71,110 @@ func (w *SVLWriter) WriteInt(vals []int) error {
 	return nil
 }
 
-type AutoFrag struct {
-	BDA []int
-	BAA []int
-}
-
-type AutoFragParser struct {
-	scanner *bufio.Scanner
-	result  AutoFrag
-}
+// writeAutoFrag writes BDA, BAA and fragments.
+// Fragment atoms are flattened, and split by the numbers of atoms in SVL.
+func writeAutoFrag(w *SVLWriter, autofrag *AutoFrag) error {
+	numAtoms := make([]int, len(autofrag.Fragments))
+	var atoms []int
+	charges := make([]int, len(autofrag.Fragments))
+	electrons := make([]int, len(autofrag.Fragments))
+	residues := make([]string, len(autofrag.Fragments))
+	for i, f := range autofrag.Fragments {
+		numAtoms[i] = len(f.Atoms)
+		atoms = append(atoms, f.Atoms...)
+		charges[i] = f.Charge
+		electrons[i] = f.Electrons
+		residues[i] = f.Residue
+	}
 
-func (p *AutoFragParser) scan() (string, error) {
-	if p == nil {
-		return "", errors.New("error")
+	if err := w.WriteInt(autofrag.BDA); err != nil {
+		return err
 	}
-	r := p.scanner.Scan()
-	if r {
-		return p.scanner.Text(), nil
+	if err := w.WriteInt(autofrag.BAA); err != nil {
+		return err
 	}
-	return "", errors.New("error")
-}
-
-func (p *AutoFragParser) parse() (*AutoFrag, error) {
-	for {
-		line, err := p.scan()
-		if err != nil {
-			return &p.result, errors.New("error")
-		}
-		if strings.Contains(line, "Frag.   Bonded Atom  Proj.") {
-			break
-		}
+	if err := w.WriteInt(numAtoms); err != nil {
+		return err
 	}
-	for {
-		line, err := p.scan()
-		if err != nil {
-			return &p.result, errors.New("error")
-		}
-		if len(line) < 13 {
-			break
-		}
-		if _, err := strconv.Atoi(strings.TrimSpace(line[0:13])); err != nil {
-			break
-		} else {
-			if v, err := strconv.Atoi(strings.TrimSpace(line[14:21])); err == nil {
-				p.result.BDA = append(p.result.BDA, v)
-			} else {
-				return &p.result, errors.New("error")
-			}
-			if v, err := strconv.Atoi(strings.TrimSpace(line[22:27])); err == nil {
-				p.result.BAA = append(p.result.BAA, v)
-			} else {
-				return &p.result, errors.New("error")
-			}
-		}
+	if err := w.WriteInt(atoms); err != nil {
+		return err
 	}
-	return &p.result, nil
-}
-
-func ParseAutoFrag(reader io.Reader) (*AutoFrag, error) {
-	parser := AutoFragParser{scanner: bufio.NewScanner(reader)}
-	return parser.parse()
+	if err := w.WriteInt(charges); err != nil {
+		return err
+	}
+	if err := w.WriteInt(electrons); err != nil {
+		return err
+	}
+	if err := w.WriteToken(residues); err != nil {
+		return err
+	}
+	return nil
 }
 
+// exit codes
 const (
 	ok                int = 0
-	optionParseFailed     = 1
-	ioError               = 2
-	parseError            = 3
+	optionParseFailed     = 1 // invalid command line options
+	ioError               = 2 // failed to read the log or write the output
+	parseError            = 3 // the log or ajf is broken or has no fragmentation
 )
 
+func isAjf(opts options) bool {
+	switch opts.Input {
+	case "ajf":
+		return true
+	case "log":
+		return false
+	}
+	return strings.EqualFold(filepath.Ext(opts.AutoFrag), ".ajf")
+}
+
 func mainProcess() (int, error) {
 	var opts options
 	if _, err := flags.ParseArgs(&opts, os.Args); err != nil {
 		return optionParseFailed, err
 	}
-	var file *os.File
 	if opts.AutoFrag == "" {
-		return ioError, errors.New("input isn't set")
-	} else {
-		file, _ = os.Open(opts.AutoFrag)
+		return optionParseFailed, errors.New("the required flag `-i, --input' was not specified")
+	}
+	file, err := os.Open(opts.AutoFrag)
+	if err != nil {
+		return ioError, err
 	}
 	defer file.Close()
-	var autofrag, _ = ParseAutoFrag(file)
+	var autofrag *AutoFrag
+	if isAjf(opts) {
+		autofrag, err = ParseAjfFragment(file)
+	} else {
+		autofrag, err = ParseAutoFrag(file)
+	}
+	if err != nil {
+		var perr *ParseError
+		if errors.As(err, &perr) || IsTableNotFound(err) || isAjfParseError(err) {
+			return parseError, fmt.Errorf("%s: %w", opts.AutoFrag, err)
+		}
+		return ioError, fmt.Errorf("%s: %w", opts.AutoFrag, err)
+	}
+	var structure *pdb.Structure
+	if opts.Pdb != "" {
+		f, err := os.Open(opts.Pdb)
+		if err != nil {
+			return ioError, err
+		}
+		defer f.Close()
+		structure, err = pdb.Read(f)
+		if err != nil {
+			var perr *pdb.ParseError
+			if errors.As(err, &perr) {
+				return parseError, fmt.Errorf("%s: %w", opts.Pdb, err)
+			}
+			return ioError, fmt.Errorf("%s: %w", opts.Pdb, err)
+		}
+		if err := labelFragments(autofrag, structure, isAjf(opts)); err != nil {
+			return parseError, fmt.Errorf("%s: %w", opts.Pdb, err)
+		}
+	}
+
 	var output *os.File
 	if opts.SvlBin == "" {
 		output = os.Stdout
@@ -142,10 +186,27 @@ func mainProcess() (int, error) {
 		}
 	}
 	defer output.Close()
+
+	switch opts.Format {
+	case "json":
+		if err := writeJSON(output, labelAutoFrag(autofrag, structure)); err != nil {
+			return ioError, err
+		}
+		return ok, nil
+	case "tsv":
+		if err := writeTSV(output, labelAutoFrag(autofrag, structure)); err != nil {
+			return ioError, err
+		}
+		return ok, nil
+	}
+
 	var writer = NewSVLWriter(output)
-	writer.WriteInt(autofrag.BDA)
-	writer.WriteInt(autofrag.BAA)
-	writer.Flush()
+	if err := writeAutoFrag(&writer, autofrag); err != nil {
+		return ioError, err
+	}
+	if err := writer.Flush(); err != nil {
+		return ioError, err
+	}
 	return ok, nil
 }
 
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/philopon/fmoe/ajf/pdb"
+)
+
+type labeledAtom struct {
+	Serial   int    `json:"serial"`
+	Fragment int    `json:"fragment,omitempty"`
+	Name     string `json:"name,omitempty"`
+	ResName  string `json:"res_name,omitempty"`
+	ChainID  string `json:"chain_id,omitempty"`
+	ResSeq   int    `json:"res_seq,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+type labeledFragment struct {
+	Index     int           `json:"index"`
+	Residue   string        `json:"residue,omitempty"`
+	Charge    int           `json:"charge"`
+	Electrons int           `json:"electrons"`
+	Atoms     []labeledAtom `json:"atoms"`
+}
+
+type labeledBond struct {
+	BDA labeledAtom `json:"bda"`
+	BAA labeledAtom `json:"baa"`
+}
+
+type labeledAutoFrag struct {
+	Fragments []labeledFragment `json:"fragments"`
+	Bonds     []labeledBond     `json:"bonds"`
+}
+
+// labelAutoFrag labels atoms of autofrag result by the ReadGeom pdb atoms, whose 1-origin indices are the atom numbers. structure may be nil.
+func labelAutoFrag(autofrag *AutoFrag, structure *pdb.Structure) labeledAutoFrag {
+	fragmentOf := make(map[int]int)
+	for _, f := range autofrag.Fragments {
+		for _, a := range f.Atoms {
+			fragmentOf[a] = f.Index
+		}
+	}
+	label := func(serial int) labeledAtom {
+		r := labeledAtom{Serial: serial, Fragment: fragmentOf[serial]}
+		if structure != nil && serial >= 1 && serial <= len(structure.Atoms) {
+			a := &structure.Atoms[serial-1]
+			r.Name = a.Name
+			r.ResName = a.ResName
+			r.ChainID = a.ChainID
+			r.ResSeq = a.ResSeq
+			r.Label = a.Label()
+		}
+		return r
+	}
+
+	result := labeledAutoFrag{
+		Fragments: make([]labeledFragment, len(autofrag.Fragments)),
+		Bonds:     make([]labeledBond, len(autofrag.BDA)),
+	}
+	for i, f := range autofrag.Fragments {
+		atoms := make([]labeledAtom, len(f.Atoms))
+		for j, a := range f.Atoms {
+			atoms[j] = label(a)
+		}
+		result.Fragments[i] = labeledFragment{
+			Index:     f.Index,
+			Residue:   f.Residue,
+			Charge:    f.Charge,
+			Electrons: f.Electrons,
+			Atoms:     atoms,
+		}
+	}
+	for i := range autofrag.BDA {
+		result.Bonds[i] = labeledBond{BDA: label(autofrag.BDA[i]), BAA: label(autofrag.BAA[i])}
+	}
+	return result
+}
+
+func writeJSON(w io.Writer, autofrag labeledAutoFrag) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(autofrag)
+}
+
+// writeTSV writes fragment membership of atoms and detached bonds as two tab separated tables.
+func writeTSV(w io.Writer, autofrag labeledAutoFrag) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#fragment\tresidue\tcharge\telectrons\tatom\tname\tres_name\tchain_id\tres_seq")
+	for _, f := range autofrag.Fragments {
+		for _, a := range f.Atoms {
+			fmt.Fprintf(bw, "%d\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%d\n",
+				f.Index, f.Residue, f.Charge, f.Electrons, a.Serial, a.Name, a.ResName, a.ChainID, a.ResSeq)
+		}
+	}
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "#bda\tbda_fragment\tbda_label\tbaa\tbaa_fragment\tbaa_label")
+	for _, b := range autofrag.Bonds {
+		fmt.Fprintf(bw, "%d\t%d\t%s\t%d\t%d\t%s\n",
+			b.BDA.Serial, b.BDA.Fragment, b.BDA.Label, b.BAA.Serial, b.BAA.Fragment, b.BAA.Label)
+	}
+	return bw.Flush()
+}
@@ -57,6 +57,14 @@ type Cpf struct {
 	DimerDI        []float64
 	DimerEX        []float64
 	DimerCT        []float64
+
+	// DimerI and DimerJ are fragments of each dimer (DimerI > DimerJ).
+	// They are nil when Dimer* fields hold all N*(N-1)/2 dimers in CPF order.
+	DimerI []int `json:",omitempty"`
+	DimerJ []int `json:",omitempty"`
+
+	// Sparse holds DI, EX and CT instead of DimerDI, DimerEX and DimerCT after Sparsify
+	Sparse *SparseDimers `json:",omitempty"`
 }
 
 type cpfParser struct {
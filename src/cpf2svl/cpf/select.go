@@ -0,0 +1,165 @@
+package cpf
+
+import (
+	"fmt"
+)
+
+// FieldGroup is group of Cpf fields
+type FieldGroup string
+
+const (
+	// Atoms is atom indices, elements, types, residues, fragments, coordinates, chain ids and insertion codes
+	Atoms FieldGroup = "atoms"
+	// Charges is Mulliken, NBO and RESP charges of atoms
+	Charges FieldGroup = "charges"
+	// Bonds is bonds between fragments
+	Bonds FieldGroup = "bonds"
+	// Dimers is dimer distances and interaction energies
+	Dimers FieldGroup = "dimers"
+)
+
+// FieldGroups is all field groups
+var FieldGroups = []FieldGroup{Atoms, Charges, Bonds, Dimers}
+
+// UnknownFieldGroup error
+type UnknownFieldGroup struct{ Group FieldGroup }
+
+func (err *UnknownFieldGroup) Error() string {
+	return fmt.Sprintf("unknown field group: %s", err.Group)
+}
+
+// Drop clears fields of the group
+func (cpf *Cpf) Drop(group FieldGroup) error {
+	switch group {
+	case Atoms:
+		cpf.AtomIndices = nil
+		cpf.AtomElements = nil
+		cpf.AtomTypes = nil
+		cpf.AtomResNames = nil
+		cpf.AtomResIndices = nil
+		cpf.AtomFragIndices = nil
+		cpf.AtomX = nil
+		cpf.AtomY = nil
+		cpf.AtomZ = nil
+		cpf.AtomChainID = nil
+		cpf.AtomInsCode = nil
+	case Charges:
+		cpf.AtomHFMulliken = nil
+		cpf.AtomMP2Mulliken = nil
+		cpf.AtomHFNBO = nil
+		cpf.AtomMP2NBO = nil
+		cpf.AtomHFRESP = nil
+		cpf.AtomMP2RESP = nil
+	case Bonds:
+		cpf.FragBondNumbers = nil
+		cpf.FragBondSelfs = nil
+		cpf.FragBondOthers = nil
+	case Dimers:
+		cpf.DimerDistances = nil
+		cpf.DimerES = nil
+		cpf.DimerDI = nil
+		cpf.DimerEX = nil
+		cpf.DimerCT = nil
+		cpf.DimerI = nil
+		cpf.DimerJ = nil
+		cpf.Sparse = nil
+	default:
+		return &UnknownFieldGroup{Group: group}
+	}
+	return nil
+}
+
+// Keep clears fields of groups not listed
+func (cpf *Cpf) Keep(groups []FieldGroup) error {
+	kept := make(map[FieldGroup]bool, len(groups))
+	for _, group := range groups {
+		kept[group] = true
+	}
+	for _, group := range FieldGroups {
+		if kept[group] {
+			delete(kept, group)
+		} else if err := cpf.Drop(group); err != nil {
+			return err
+		}
+	}
+	for group := range kept {
+		return &UnknownFieldGroup{Group: group}
+	}
+	return nil
+}
+
+// DimerIndex returns index of dimer of fragment i and j (1-origin) in Dimer* fields of dense cpf
+func DimerIndex(i, j int) int {
+	if i < j {
+		i, j = j, i
+	}
+	return (i-1)*(i-2)/2 + j - 1
+}
+
+// SelectDimers keeps only dimers involving any of frags (1-origin).
+// Fragments of kept dimers are stored in DimerI and DimerJ.
+func (cpf *Cpf) SelectDimers(frags []int) {
+	selected := make([]bool, cpf.NumFrags+1)
+	for _, f := range frags {
+		if 0 < f && f <= cpf.NumFrags {
+			selected[f] = true
+		}
+	}
+
+	var kept []int
+	dimerI := make([]int, 0)
+	dimerJ := make([]int, 0)
+	keep := func(k, i, j int) {
+		if selected[i] || selected[j] {
+			kept = append(kept, k)
+			dimerI = append(dimerI, i)
+			dimerJ = append(dimerJ, j)
+		}
+	}
+	if cpf.DimerI != nil {
+		for k := range cpf.DimerI {
+			keep(k, cpf.DimerI[k], cpf.DimerJ[k])
+		}
+	} else {
+		k := 0
+		for i := 2; i <= cpf.NumFrags; i++ {
+			for j := 1; j < i; j++ {
+				keep(k, i, j)
+				k++
+			}
+		}
+	}
+
+	pick := func(vals []float64) []float64 {
+		if vals == nil {
+			return nil
+		}
+		r := make([]float64, len(kept))
+		for n, k := range kept {
+			r[n] = vals[k]
+		}
+		return r
+	}
+	cpf.DimerDistances = pick(cpf.DimerDistances)
+	cpf.DimerES = pick(cpf.DimerES)
+	cpf.DimerDI = pick(cpf.DimerDI)
+	cpf.DimerEX = pick(cpf.DimerEX)
+	cpf.DimerCT = pick(cpf.DimerCT)
+	cpf.DimerI = dimerI
+	cpf.DimerJ = dimerJ
+
+	if cpf.Sparse != nil {
+		sparse := SparseDimers{I: []int{}, J: []int{}, DI: []float64{}, EX: []float64{}, CT: []float64{}}
+		for n := range cpf.Sparse.I {
+			i, j := cpf.Sparse.I[n], cpf.Sparse.J[n]
+			if selected[i] || selected[j] {
+				sparse.I = append(sparse.I, i)
+				sparse.J = append(sparse.J, j)
+				sparse.DI = append(sparse.DI, cpf.Sparse.DI[n])
+				sparse.EX = append(sparse.EX, cpf.Sparse.EX[n])
+				sparse.CT = append(sparse.CT, cpf.Sparse.CT[n])
+			}
+		}
+		cpf.Sparse = &sparse
+	}
+}
@@ -0,0 +1,56 @@
+package cpf
+
+// SparseDimers is DI, EX and CT of dimers, any of which is non-zero.
+// Dimers approximated by ES have zero DI, EX and CT, and are not stored.
+type SparseDimers struct {
+	// I and J are fragments of each dimer (I > J)
+	I  []int
+	J  []int
+	DI []float64
+	EX []float64
+	CT []float64
+}
+
+// Sparsify moves DI, EX and CT of non-zero dimers to Sparse.
+// DimerDI, DimerEX and DimerCT are cleared.
+func (cpf *Cpf) Sparsify() {
+	if cpf.Sparse != nil || cpf.DimerDI == nil {
+		return
+	}
+
+	sparse := SparseDimers{
+		I:  make([]int, 0),
+		J:  make([]int, 0),
+		DI: make([]float64, 0),
+		EX: make([]float64, 0),
+		CT: make([]float64, 0),
+	}
+	add := func(k, i, j int) {
+		if cpf.DimerDI[k] == 0 && cpf.DimerEX[k] == 0 && cpf.DimerCT[k] == 0 {
+			return
+		}
+		sparse.I = append(sparse.I, i)
+		sparse.J = append(sparse.J, j)
+		sparse.DI = append(sparse.DI, cpf.DimerDI[k])
+		sparse.EX = append(sparse.EX, cpf.DimerEX[k])
+		sparse.CT = append(sparse.CT, cpf.DimerCT[k])
+	}
+	if cpf.DimerI != nil {
+		for k := range cpf.DimerI {
+			add(k, cpf.DimerI[k], cpf.DimerJ[k])
+		}
+	} else {
+		k := 0
+		for i := 2; i <= cpf.NumFrags; i++ {
+			for j := 1; j < i; j++ {
+				add(k, i, j)
+				k++
+			}
+		}
+	}
+
+	cpf.Sparse = &sparse
+	cpf.DimerDI = nil
+	cpf.DimerEX = nil
+	cpf.DimerCT = nil
+}
@@ -10,15 +10,24 @@ import (
 
 	flags "github.com/jessevdk/go-flags"
 	"github.com/philopon/fmoe/cpf2svl/cpf"
+	"github.com/philopon/fmoe/cpf2svl/svlreader"
 	"github.com/philopon/fmoe/cpf2svl/svlwriter"
 )
 
 type options struct {
-	CpfPath string `short:"i" long:"input" description:"input cpf file" env:"CPF_PATH"`
-	SvlPath string `short:"o" long:"output" description:"output moe binary file" env:"SVL_PATH"`
-	JSON    bool   `short:"j" long:"json" description:"json output"`
+	CpfPath string   `short:"i" long:"input" description:"input cpf file" env:"CPF_PATH"`
+	SvlPath string   `short:"o" long:"output" description:"output moe binary file" env:"SVL_PATH"`
+	JSON    bool     `short:"j" long:"json" description:"json output"`
+	Dump    bool     `short:"d" long:"dump" description:"dump moe binary input as json"`
+	Tagged  bool     `short:"t" long:"tagged" description:"write moe binary as self-describing tagged vector"`
+	Fields  []string `short:"f" long:"field" description:"field group to output (default: all)" choice:"atoms" choice:"charges" choice:"bonds" choice:"dimers" env:"CPF_FIELDS" env-delim:","`
+	Sparse  bool     `short:"s" long:"sparse" description:"store DI, EX and CT of only non-zero dimers"`
+	Frags   []int    `long:"frag" description:"output only dimers involving the fragment" env:"CPF_FRAGS" env-delim:","`
 }
 
+// version is cpf2svl version, overwritten by -ldflags "-X main.version=..."
+var version = "dev"
+
 const (
 	ok                int = 0
 	optionParseFailed     = 1
@@ -32,7 +41,7 @@ func mainProcess() (int, error) {
 		return optionParseFailed, err
 	}
 
-	if !opts.JSON && opts.SvlPath == "" {
+	if !opts.JSON && !opts.Dump && opts.SvlPath == "" {
 		return optionParseFailed, fmt.Errorf("the required flag `-o, --output' was not specified")
 	}
 
@@ -59,9 +68,24 @@ func mainProcess() (int, error) {
 		input = file
 	}
 
-	cpf, err := cpf.ParseCpf(input)
-	if err != nil {
-		return parseError, err
+	var vectors []svlreader.Vector
+	var data *cpf.Cpf
+	if opts.Dump {
+		r := svlreader.NewSVLReader(input)
+		var err error
+		vectors, err = r.ReadAll()
+		if err != nil {
+			return parseError, err
+		}
+	} else {
+		var err error
+		data, err = cpf.ParseCpf(input)
+		if err != nil {
+			return parseError, err
+		}
+		if err := selectCpf(data, opts); err != nil {
+			return optionParseFailed, err
+		}
 	}
 
 	var output *os.File
@@ -76,13 +100,36 @@ func mainProcess() (int, error) {
 	}
 	defer output.Close()
 
-	if opts.JSON {
+	if opts.Dump {
+		enc := json.NewEncoder(output)
+		if err := enc.Encode(vectors); err != nil {
+			return ioError, err
+		}
+	} else if opts.JSON {
 		enc := json.NewEncoder(output)
-		enc.Encode(cpf)
+		enc.Encode(data)
 
 	} else {
 		w := svlwriter.NewSVLWriter(output)
-		if err := writeCpf(&w, cpf); err != nil {
+		h := header{
+			Layout:        layoutPositional,
+			Fields:        fieldNames(opts),
+			DimerEncoding: dimerDense,
+			CpfPath:       opts.CpfPath,
+			CpfVersion:    data.Version,
+		}
+		if data.Sparse != nil {
+			h.DimerEncoding = dimerSparse
+		}
+		write := writeCpf
+		if opts.Tagged {
+			h.Layout = layoutTagged
+			write = writeCpfTagged
+		}
+		if err := writeHeader(&w, h); err != nil {
+			return ioError, err
+		}
+		if err := write(&w, data); err != nil {
 			return ioError, err
 		}
 		if err := w.Flush(); err != nil {
@@ -93,6 +140,46 @@ func mainProcess() (int, error) {
 	return ok, nil
 }
 
+func fieldNames(opts options) []string {
+	if len(opts.Fields) > 0 {
+		return opts.Fields
+	}
+	names := make([]string, len(cpf.FieldGroups))
+	for i, group := range cpf.FieldGroups {
+		names[i] = string(group)
+	}
+	return names
+}
+
+func selectCpf(data *cpf.Cpf, opts options) error {
+	dimers := true
+	if len(opts.Fields) > 0 {
+		groups := make([]cpf.FieldGroup, len(opts.Fields))
+		dimers = false
+		for i, f := range opts.Fields {
+			groups[i] = cpf.FieldGroup(f)
+			dimers = dimers || groups[i] == cpf.Dimers
+		}
+		if err := data.Keep(groups); err != nil {
+			return err
+		}
+	}
+	// empty DimerI is read as all dimers, so every fragment must be in range
+	for _, f := range opts.Frags {
+		if f < 1 || f > data.NumFrags {
+			return fmt.Errorf("fragment %d of --frag is out of range [1, %d]", f, data.NumFrags)
+		}
+	}
+	// --frag selects nothing without dimers, and DimerI and DimerJ are left empty
+	if len(opts.Frags) > 0 && dimers {
+		data.SelectDimers(opts.Frags)
+	}
+	if opts.Sparse {
+		data.Sparsify()
+	}
+	return nil
+}
+
 func main() {
 	code, err := mainProcess()
 	if err != nil {
@@ -0,0 +1,196 @@
+package svlreader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Type is moe binary vector type
+type Type byte
+
+const (
+	// Int is MOE integer vector
+	Int Type = 2
+	// Float is MOE float vector
+	Float Type = 3
+	// Token is MOE token vector
+	Token Type = 4
+	// Nested is MOE nested vector
+	Nested Type = 5
+)
+
+// Vector is decoded MOE vector
+type Vector struct {
+	Type   Type
+	Ints   []int
+	Floats []float64
+	Tokens []string
+	Nested []Vector
+}
+
+// MarshalJSON encodes vector as plain json array, or json object if vector is tagged
+func (v Vector) MarshalJSON() ([]byte, error) {
+	if v.Tagged() {
+		return v.marshalTagged()
+	}
+	switch v.Type {
+	case Int:
+		if v.Ints == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(v.Ints)
+	case Float:
+		if v.Floats == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(v.Floats)
+	case Token:
+		if v.Tokens == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(v.Tokens)
+	case Nested:
+		if v.Nested == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(v.Nested)
+	}
+	return nil, &UnknownType{Type: byte(v.Type)}
+}
+
+func (v *Vector) marshalTagged() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, t := range v.Nested[0].Tokens {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(v.Nested[1].Nested[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnknownType error
+type UnknownType struct{ Type byte }
+
+func (err *UnknownType) Error() string {
+	return fmt.Sprintf("unknown moe binary type: %d", err.Type)
+}
+
+// SVLReader is moe binary file reader type
+type SVLReader struct {
+	reader *bufio.Reader
+}
+
+// NewSVLReader creates new SVLReader type
+func NewSVLReader(reader io.Reader) SVLReader {
+	return SVLReader{reader: bufio.NewReader(reader)}
+}
+
+func (r *SVLReader) readSize() (int, error) {
+	var v uint32
+	if err := binary.Read(r.reader, binary.BigEndian, &v); err != nil {
+		return 0, err
+	}
+	return int(v), nil
+}
+
+// Read reads one MOE vector. It returns io.EOF when no vector is left.
+func (r *SVLReader) Read() (*Vector, error) {
+	t, err := r.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	v, err := r.readBody(Type(t))
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (r *SVLReader) readBody(t Type) (*Vector, error) {
+	size, err := r.readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	v := Vector{Type: t}
+	switch t {
+	case Int:
+		v.Ints = make([]int, size)
+		for i := range v.Ints {
+			var val int32
+			if err := binary.Read(r.reader, binary.BigEndian, &val); err != nil {
+				return nil, err
+			}
+			v.Ints[i] = int(val)
+		}
+	case Float:
+		v.Floats = make([]float64, size)
+		if err := binary.Read(r.reader, binary.BigEndian, v.Floats); err != nil {
+			return nil, err
+		}
+	case Token:
+		v.Tokens = make([]string, size)
+		for i := range v.Tokens {
+			l, err := r.readSize()
+			if err != nil {
+				return nil, err
+			}
+			buf := make([]byte, l)
+			if _, err := io.ReadFull(r.reader, buf); err != nil {
+				return nil, err
+			}
+			v.Tokens[i] = string(buf)
+		}
+	case Nested:
+		v.Nested = make([]Vector, size)
+		for i := range v.Nested {
+			child, err := r.Read()
+			if err != nil {
+				return nil, err
+			}
+			v.Nested[i] = *child
+		}
+	default:
+		return nil, &UnknownType{Type: byte(t)}
+	}
+	return &v, nil
+}
+
+// ReadAll reads MOE vectors until end of file
+func (r *SVLReader) ReadAll() ([]Vector, error) {
+	var vs []Vector
+	for {
+		v, err := r.Read()
+		if err == io.EOF {
+			return vs, nil
+		}
+		if err != nil {
+			return vs, err
+		}
+		vs = append(vs, *v)
+	}
+}
+
+// Tagged checks vector is written by SVLWriter.WriteTagged or not
+func (v *Vector) Tagged() bool {
+	return v.Type == Nested && len(v.Nested) == 2 &&
+		v.Nested[0].Type == Token && v.Nested[1].Type == Nested &&
+		len(v.Nested[0].Tokens) == len(v.Nested[1].Nested)
+}
@@ -75,3 +75,25 @@ func (w *SVLWriter) WriteFloat(vals []float64) error {
 	}
 	return nil
 }
+
+// WriteNested writes header of MOE nested vector.
+// The following size vectors are written as its elements.
+func (w *SVLWriter) WriteNested(size int) error {
+	if err := w.writer.WriteByte(5); err != nil {
+		return err
+	}
+	return w.writeSize(uint32(size))
+}
+
+// WriteTagged writes header of MOE tagged vector.
+// Tagged vector is written as nested vector [tags, values], which is restored by `tag` in SVL.
+// The following len(tags) vectors are written as its values.
+func (w *SVLWriter) WriteTagged(tags []string) error {
+	if err := w.WriteNested(2); err != nil {
+		return err
+	}
+	if err := w.WriteToken(tags); err != nil {
+		return err
+	}
+	return w.WriteNested(len(tags))
+}
@@ -5,91 +5,176 @@ import (
 	"github.com/philopon/fmoe/cpf2svl/svlwriter"
 )
 
-func writeCpf(writer *svlwriter.SVLWriter, cpf *cpf.Cpf) error {
-	if err := writer.WriteInt([]int{int(cpf.NumAtoms)}); err != nil {
-		return err
-	}
-	if err := writer.WriteInt([]int{int(cpf.NumFrags)}); err != nil {
-		return err
-	}
+// formatVersion is version of moe binary written by cpf2svl.
+// Binaries without header are version 1.
+// Increment it when layout of the vectors is changed.
+const formatVersion = 2
 
-	if err := writer.WriteInt(cpf.AtomIndices); err != nil {
-		return err
-	}
-	if err := writer.WriteToken(cpf.AtomElements); err != nil {
-		return err
-	}
-	if err := writer.WriteToken(cpf.AtomTypes); err != nil {
-		return err
-	}
-	if err := writer.WriteToken(cpf.AtomResNames); err != nil {
-		return err
-	}
-	if err := writer.WriteInt(cpf.AtomResIndices); err != nil {
-		return err
-	}
-	if err := writer.WriteInt(cpf.AtomFragIndices); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.AtomX); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.AtomY); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.AtomZ); err != nil {
+const headerMagic = "FMOE_CPF2SVL"
+
+const (
+	layoutPositional = "positional"
+	layoutTagged     = "tagged"
+)
+
+const (
+	dimerDense  = "dense"
+	dimerSparse = "sparse"
+)
+
+type header struct {
+	Layout        string
+	Fields        []string
+	DimerEncoding string
+	CpfPath       string
+	CpfVersion    cpf.Version
+}
+
+// writeHeader writes tagged header vector put before cpf data
+func writeHeader(writer *svlwriter.SVLWriter, h header) error {
+	if err := writer.WriteTagged([]string{
+		"magic", "format_version", "layout", "fields", "dimer_encoding", "cpf2svl_version", "cpf_path", "cpf_version",
+	}); err != nil {
 		return err
 	}
-	if err := writer.WriteFloat(cpf.AtomHFMulliken); err != nil {
+	if err := writer.WriteToken([]string{headerMagic}); err != nil {
 		return err
 	}
-	if err := writer.WriteFloat(cpf.AtomMP2Mulliken); err != nil {
+	if err := writer.WriteInt([]int{formatVersion}); err != nil {
 		return err
 	}
-	if err := writer.WriteFloat(cpf.AtomHFNBO); err != nil {
+	if err := writer.WriteToken([]string{h.Layout}); err != nil {
 		return err
 	}
-	if err := writer.WriteFloat(cpf.AtomMP2NBO); err != nil {
+	if err := writer.WriteToken(h.Fields); err != nil {
 		return err
 	}
-	if err := writer.WriteFloat(cpf.AtomHFRESP); err != nil {
+	if err := writer.WriteToken([]string{h.DimerEncoding}); err != nil {
 		return err
 	}
-	if err := writer.WriteFloat(cpf.AtomMP2RESP); err != nil {
+	if err := writer.WriteToken([]string{version}); err != nil {
 		return err
 	}
-	if err := writer.WriteToken(cpf.AtomChainID); err != nil {
+	if err := writer.WriteToken([]string{h.CpfPath}); err != nil {
 		return err
 	}
-	if err := writer.WriteToken(cpf.AtomInsCode); err != nil {
+	if err := writer.WriteInt([]int{int(h.CpfVersion)}); err != nil {
 		return err
 	}
+	return nil
+}
 
-	if err := writer.WriteInt(cpf.FragBondNumbers); err != nil {
-		return err
-	}
-	if err := writer.WriteInt(cpf.FragBondSelfs); err != nil {
-		return err
-	}
-	if err := writer.WriteInt(cpf.FragBondOthers); err != nil {
-		return err
+// sparseDimers returns DI, EX and CT vectors and their fragments.
+// Fragments are nil for dense dimers.
+func sparseDimers(cpf *cpf.Cpf) (i, j []int, di, ex, ct []float64) {
+	if cpf.Sparse == nil {
+		return nil, nil, cpf.DimerDI, cpf.DimerEX, cpf.DimerCT
 	}
+	return cpf.Sparse.I, cpf.Sparse.J, cpf.Sparse.DI, cpf.Sparse.EX, cpf.Sparse.CT
+}
 
-	if err := writer.WriteFloat(cpf.DimerDistances); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.DimerES); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.DimerDI); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.DimerEX); err != nil {
-		return err
-	}
-	if err := writer.WriteFloat(cpf.DimerCT); err != nil {
-		return err
+// cpfVector is a vector of cpf2svl output.
+// Vectors of a group, e.g. atoms, are nested in a tagged vector in the tagged layout, and flattened in the positional layout.
+type cpfVector struct {
+	tag    string
+	write  func(writer *svlwriter.SVLWriter) error
+	nested []cpfVector
+	// taggedOnly vectors are not in the positional layout
+	taggedOnly bool
+}
+
+func intVector(tag string, vals []int) cpfVector {
+	return cpfVector{tag: tag, write: func(writer *svlwriter.SVLWriter) error { return writer.WriteInt(vals) }}
+}
+
+func floatVector(tag string, vals []float64) cpfVector {
+	return cpfVector{tag: tag, write: func(writer *svlwriter.SVLWriter) error { return writer.WriteFloat(vals) }}
+}
+
+func tokenVector(tag string, vals []string) cpfVector {
+	return cpfVector{tag: tag, write: func(writer *svlwriter.SVLWriter) error { return writer.WriteToken(vals) }}
+}
+
+// cpfVectors is the vectors of cpf in the order of both layouts.
+// The positional layout is indexed by CPF_* constants of visualization.svl.
+func cpfVectors(cpf *cpf.Cpf) []cpfVector {
+	sparseI, sparseJ, dimerDI, dimerEX, dimerCT := sparseDimers(cpf)
+	version := intVector("version", []int{int(cpf.Version)})
+	version.taggedOnly = true
+	return []cpfVector{
+		version,
+		intVector("num_atoms", []int{int(cpf.NumAtoms)}),
+		intVector("num_frags", []int{int(cpf.NumFrags)}),
+		{tag: "atoms", nested: []cpfVector{
+			intVector("index", cpf.AtomIndices),
+			tokenVector("element", cpf.AtomElements),
+			tokenVector("type", cpf.AtomTypes),
+			tokenVector("res_name", cpf.AtomResNames),
+			intVector("res_index", cpf.AtomResIndices),
+			intVector("frag_index", cpf.AtomFragIndices),
+			floatVector("x", cpf.AtomX),
+			floatVector("y", cpf.AtomY),
+			floatVector("z", cpf.AtomZ),
+			floatVector("hf_mulliken", cpf.AtomHFMulliken),
+			floatVector("mp2_mulliken", cpf.AtomMP2Mulliken),
+			floatVector("hf_nbo", cpf.AtomHFNBO),
+			floatVector("mp2_nbo", cpf.AtomMP2NBO),
+			floatVector("hf_resp", cpf.AtomHFRESP),
+			floatVector("mp2_resp", cpf.AtomMP2RESP),
+			tokenVector("chain_id", cpf.AtomChainID),
+			tokenVector("ins_code", cpf.AtomInsCode),
+		}},
+		{tag: "frag_bonds", nested: []cpfVector{
+			intVector("numbers", cpf.FragBondNumbers),
+			intVector("selfs", cpf.FragBondSelfs),
+			intVector("others", cpf.FragBondOthers),
+		}},
+		{tag: "dimers", nested: []cpfVector{
+			floatVector("distance", cpf.DimerDistances),
+			floatVector("es", cpf.DimerES),
+			floatVector("di", dimerDI),
+			floatVector("ex", dimerEX),
+			floatVector("ct", dimerCT),
+			intVector("i", cpf.DimerI),
+			intVector("j", cpf.DimerJ),
+			intVector("sparse_i", sparseI),
+			intVector("sparse_j", sparseJ),
+		}},
 	}
+}
 
+// writeVectors writes the vectors as tagged vectors, or flat in the positional layout
+func writeVectors(writer *svlwriter.SVLWriter, vectors []cpfVector, tagged bool) error {
+	if tagged {
+		tags := make([]string, len(vectors))
+		for i, v := range vectors {
+			tags[i] = v.tag
+		}
+		if err := writer.WriteTagged(tags); err != nil {
+			return err
+		}
+	}
+	for _, v := range vectors {
+		if v.taggedOnly && !tagged {
+			continue
+		}
+		var err error
+		if v.nested != nil {
+			err = writeVectors(writer, v.nested, tagged)
+		} else {
+			err = v.write(writer)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+func writeCpf(writer *svlwriter.SVLWriter, cpf *cpf.Cpf) error {
+	return writeVectors(writer, cpfVectors(cpf), false)
+}
+
+func writeCpfTagged(writer *svlwriter.SVLWriter, cpf *cpf.Cpf) error {
+	return writeVectors(writer, cpfVectors(cpf), true)
+}
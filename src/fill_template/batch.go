@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+// loadManifest reads rows of a batch manifest.
+// A json manifest is an array of objects, and a csv (or tsv) manifest has variable names in the header row.
+// Empty cells of csv are not set, so the data or environment variables are used for them.
+func loadManifest(path string) ([]map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var rows []map[string]interface{}
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, &DataError{Path: path, Cause: err}
+		}
+		return rows, nil
+	case ".tsv":
+		return readCSV(path, f, '\t')
+	}
+	return readCSV(path, f, ',')
+}
+
+func readCSV(path string, reader io.Reader, comma rune) ([]map[string]interface{}, error) {
+	r := csv.NewReader(reader)
+	r.Comma = comma
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, &DataError{Path: path, Cause: errors.New("no header row")}
+	}
+	if err != nil {
+		return nil, &DataError{Path: path, Cause: err}
+	}
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+	}
+	var rows []map[string]interface{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, &DataError{Path: path, Cause: err}
+		}
+		row := make(map[string]interface{}, len(header))
+		for i, value := range record {
+			if value != "" {
+				row[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// cloneData copies data deeply, so that merging rows does not modify the base data
+func cloneData(data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if m, ok := value.(map[string]interface{}); ok {
+			value = cloneData(m)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// BatchFailed error
+type BatchFailed struct {
+	Failed int
+	Total  int
+}
+
+func (err *BatchFailed) Error() string {
+	return fmt.Sprintf("%d of %d rows failed", err.Failed, err.Total)
+}
+
+// batch renders a template for each row of a manifest, merged over the data.
+// The output path of each row is rendered from the pattern, e.g. "{{{BASENAME}}}.ajf" without HTML escaping,
+// which is the output directory of a multi-document template.
+type batch struct {
+	chain    []templateSource
+	helpers  map[string]interface{}
+	partials map[string]string
+	// variables are checked for each row if not nil
+	variables []Variable
+	// scheduler checks directives of rendered job scripts if not empty
+	scheduler string
+	pattern   *raymond.Template
+}
+
+func newBatch(pattern string, chain []templateSource, helpers map[string]interface{}, partials map[string]string, variables []Variable, scheduler string) (*batch, error) {
+	tpl, err := raymond.Parse(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("output pattern: %w", err)
+	}
+	tpl.RegisterHelpers(helpers)
+	return &batch{chain: chain, helpers: helpers, partials: partials, variables: variables, scheduler: scheduler, pattern: tpl}, nil
+}
+
+// render renders the data to the output path, or the output directory of a multi-document template.
+// written is the files written by the previous rows, which must not be overwritten.
+func (b *batch) render(output string, data map[string]interface{}, written map[string]int) ([]string, error) {
+	if b.variables != nil {
+		if err := checkVariables(b.variables, data); err != nil {
+			return nil, err
+		}
+	}
+	result, err := renderChain(b.chain, data, b.helpers, b.partials)
+	if err != nil {
+		return nil, err
+	}
+	documents, multi, err := splitOutput(output, result)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range documents {
+		if written[d.Path] > 0 {
+			return nil, fmt.Errorf("%s is also written by row %d", d.Path, written[d.Path])
+		}
+	}
+	if err := checkDocuments(documents, multi, b.scheduler); err != nil {
+		return nil, err
+	}
+	return writeDocuments(documents)
+}
+
+// run renders all the rows, reporting failures row by row to stderr and written files to stdout
+func (b *batch) run(rows []map[string]interface{}, data map[string]interface{}) error {
+	failed := 0
+	written := make(map[string]int)
+	for i, row := range rows {
+		rowData := cloneData(data)
+		mergeData(rowData, row)
+
+		var paths []string
+		output, err := b.pattern.Exec(rowData)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("output pattern: %w", err)
+		case strings.TrimSpace(output) == "":
+			err = errors.New("output pattern is rendered as an empty path")
+		default:
+			paths, err = b.render(output, rowData, written)
+		}
+		for _, path := range paths {
+			written[path] = i + 1
+			fmt.Println(path)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "row %d: %v\n", i+1, err)
+		}
+	}
+	if failed > 0 {
+		return &BatchFailed{Failed: failed, Total: len(rows)}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envData returns environment variables as template data
+func envData() map[string]interface{} {
+	rawEnvs := os.Environ()
+	envs := make(map[string]interface{}, len(rawEnvs))
+
+	for _, rawEnv := range rawEnvs {
+		if i := strings.IndexRune(rawEnv, '='); i >= 0 {
+			envs[rawEnv[:i]] = rawEnv[i+1:]
+		}
+	}
+	return envs
+}
+
+// DataError error
+type DataError struct {
+	Path  string
+	Cause error
+}
+
+func (err *DataError) Error() string {
+	return fmt.Sprintf("%s: %v", err.Path, err.Cause)
+}
+
+func (err *DataError) Unwrap() error {
+	return err.Cause
+}
+
+// loadData reads json or yaml data file. path "-" is stdin.
+// Files of the extension .json are read as json, others as yaml, which is also able to read json.
+func loadData(path string) (map[string]interface{}, error) {
+	var reader io.Reader
+	if path == "-" {
+		reader = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	data := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.NewDecoder(reader).Decode(&data); err != nil {
+			return nil, &DataError{Path: path, Cause: err}
+		}
+		return data, nil
+	}
+	if err := yaml.NewDecoder(reader).Decode(&data); err != nil && err != io.EOF {
+		return nil, &DataError{Path: path, Cause: err}
+	}
+	return data, nil
+}
+
+// mergeData merges src over dst. Nested maps are merged recursively, other values are replaced.
+func mergeData(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeData(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// templateData builds template data. Data files are merged over environment variables, in the given order.
+func templateData(paths []string) (map[string]interface{}, error) {
+	data := envData()
+	for _, path := range paths {
+		d, err := loadData(path)
+		if err != nil {
+			return nil, err
+		}
+		mergeData(data, d)
+	}
+	return data, nil
+}
@@ -1,6 +1,13 @@
 module github.com/philopon/fmoe/fill_template
 
+go 1.16
+
 require (
 	github.com/aymerick/raymond v2.0.2+incompatible
-	github.com/jessevdk/go-flags v1.4.0
+	github.com/jessevdk/go-flags v1.5.0
+	github.com/philopon/fmoe/ajf v0.0.0
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+replace github.com/philopon/fmoe/ajf => ../ajf
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/philopon/fmoe/ajf/basisset"
+)
+
+// helperError is raised in helpers, and returned by raymond as a render error
+type helperError struct {
+	Helper string
+	Cause  error
+}
+
+func (err *helperError) Error() string {
+	return fmt.Sprintf("helper %s: %v", err.Helper, err.Cause)
+}
+
+// toNumber converts a helper parameter, which is a number of json/yaml data or a string of environment variables
+func toNumber(helper string, v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case json.Number:
+		f, err := n.Float64()
+		if err == nil {
+			return f
+		}
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err == nil {
+			return f
+		}
+	}
+	panic(&helperError{Helper: helper, Cause: fmt.Errorf("not a number: %v", v)})
+}
+
+// fromNumber returns int for integral values, so that 3000 is not rendered as 3000.0
+func fromNumber(f float64) interface{} {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return f
+}
+
+// toList converts a helper parameter, which is a list of json/yaml data or a string separated by spaces or commas
+func toList(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case []interface{}:
+		return l
+	case string:
+		fields := strings.FieldsFunc(l, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+		})
+		result := make([]interface{}, len(fields))
+		for i, f := range fields {
+			result[i] = f
+		}
+		return result
+	case nil:
+		return nil
+	}
+	return []interface{}{v}
+}
+
+func arithmetic(name string, op func(a, b float64) float64) func(a, b interface{}) interface{} {
+	return func(a, b interface{}) interface{} {
+		return fromNumber(op(toNumber(name, a), toNumber(name, b)))
+	}
+}
+
+// wrap10Helper formats integers 10 per line with width 8, as the &FRAGMENT section of ABINIT-MP
+func wrap10Helper(values interface{}) raymond.SafeString {
+	list := toList(values)
+	var lines []string
+	for i := 0; i < len(list); i += 10 {
+		end := i + 10
+		if end > len(list) {
+			end = len(list)
+		}
+		var sb strings.Builder
+		for _, v := range list[i:end] {
+			fmt.Fprintf(&sb, "%8v", fromNumber(toNumber("wrap10", v)))
+		}
+		lines = append(lines, sb.String())
+	}
+	return raymond.SafeString(strings.Join(lines, "\n"))
+}
+
+// ifMethodHelper renders the block if the method is one of the candidates separated by '|', case insensitive.
+//
+//	{{#ifMethod METHOD "MP2|MP3"}}...{{else}}...{{/ifMethod}}
+func ifMethodHelper(method string, candidates string, options *raymond.Options) interface{} {
+	for _, c := range strings.Split(candidates, "|") {
+		if strings.EqualFold(strings.TrimSpace(c), strings.TrimSpace(method)) {
+			return options.Fn()
+		}
+	}
+	return options.Inverse()
+}
+
+// defaultBasisSetPath is basisset.json of the repository, the executable is in bin
+func defaultBasisSetPath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), "..", "basisset.json")
+}
+
+// loadBasisTable reads basisset.json, and basisset/ajf.json next to it to resolve display names, e.g. 6-31G(d)
+func loadBasisTable(path string) (*basisset.Table, error) {
+	data, err := basisset.LoadData(path)
+	if err != nil {
+		return nil, err
+	}
+	table := basisset.Table{Data: data}
+	if aliases, err := basisset.LoadAliases(filepath.Join(filepath.Dir(path), "basisset", "ajf.json")); err == nil {
+		table.Aliases = aliases
+	}
+	return &table, nil
+}
+
+// countElements converts elements to counts. elements is a map of element to count,
+// or a list of elements, each of which may have a count, e.g. "C H H" or "C:1,H:2".
+func countElements(elements interface{}) map[string]int {
+	counts := make(map[string]int)
+	if m, ok := elements.(map[string]interface{}); ok {
+		for e, n := range m {
+			counts[e] += int(toNumber("basisFunctions", n))
+		}
+		return counts
+	}
+	for _, v := range toList(elements) {
+		s := fmt.Sprint(v)
+		n := 1
+		if i := strings.IndexRune(s, ':'); i >= 0 {
+			n = int(toNumber("basisFunctions", s[i+1:]))
+			s = s[:i]
+		}
+		counts[s] += n
+	}
+	return counts
+}
+
+// basisFunctionsHelper returns a helper counting basis functions of the elements.
+// basisset.json is read at the first call.
+//
+//	{{basisFunctions BASIS_SET ELEMENTS}}
+func basisFunctionsHelper(path string) func(basis string, elements interface{}) interface{} {
+	var table *basisset.Table
+	return func(basis string, elements interface{}) interface{} {
+		if table == nil {
+			var err error
+			if table, err = loadBasisTable(path); err != nil {
+				panic(&helperError{Helper: "basisFunctions", Cause: err})
+			}
+		}
+		functions, err := table.Resolve(basis)
+		if err == nil && functions == nil {
+			err = fmt.Errorf("basis set %q has no basis function data", basis)
+		}
+		if err != nil {
+			panic(&helperError{Helper: "basisFunctions", Cause: err})
+		}
+		total, err := table.Count(basis, countElements(elements))
+		if err != nil {
+			panic(&helperError{Helper: "basisFunctions", Cause: err})
+		}
+		return total
+	}
+}
+
+// helpers returns the helpers registered to templates
+func helpers(basisSetPath string) map[string]interface{} {
+	result := map[string]interface{}{
+		"add": arithmetic("add", func(a, b float64) float64 { return a + b }),
+		"sub": arithmetic("sub", func(a, b float64) float64 { return a - b }),
+		"mul": arithmetic("mul", func(a, b float64) float64 { return a * b }),
+		"div": arithmetic("div", func(a, b float64) float64 {
+			if b == 0 {
+				panic(&helperError{Helper: "div", Cause: fmt.Errorf("division by zero")})
+			}
+			return a / b
+		}),
+		"mod": arithmetic("mod", func(a, b float64) float64 {
+			if b == 0 {
+				panic(&helperError{Helper: "mod", Cause: fmt.Errorf("division by zero")})
+			}
+			return math.Mod(a, b)
+		}),
+		"min":            arithmetic("min", math.Min),
+		"max":            arithmetic("max", math.Max),
+		"ceil":           func(a interface{}) interface{} { return fromNumber(math.Ceil(toNumber("ceil", a))) },
+		"floor":          func(a interface{}) interface{} { return fromNumber(math.Floor(toNumber("floor", a))) },
+		"wrap10":         wrap10Helper,
+		"ifMethod":       ifMethodHelper,
+		"basisFunctions": basisFunctionsHelper(basisSetPath),
+	}
+	for name, helper := range schedulerHelpers() {
+		result[name] = helper
+	}
+	return result
+}
+
+// helperNames returns set of the helper names
+func helperNames(helpers map[string]interface{}) map[string]bool {
+	names := make(map[string]bool, len(helpers))
+	for name := range helpers {
+		names[name] = true
+	}
+	return names
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"strings"
 
-	"github.com/aymerick/raymond"
 	flags "github.com/jessevdk/go-flags"
 )
 
 type options struct {
-	InputPath  string `short:"i" long:"input" description:"input template file" env:"TEMPLATE_PATH"`
-	OutputPath string `short:"o" long:"output" description:"output file" env:"OUTPUT_PATH"`
+	InputPath  string   `short:"i" long:"input" description:"input template file" env:"TEMPLATE_PATH"`
+	OutputPath string   `short:"o" long:"output" description:"output file, or output directory of a multi-document template. a path pattern, e.g. {{{BASENAME}}}.ajf, with --manifest" env:"OUTPUT_PATH"`
+	Manifest   string   `short:"m" long:"manifest" description:"csv, tsv or json manifest to render the template for each row" env:"TEMPLATE_MANIFEST_PATH"`
+	DataPaths  []string `short:"d" long:"data" description:"json or yaml data file merged over environment variables, - for stdin. later files take precedence" env:"TEMPLATE_DATA_PATH"`
+	Strict     bool     `short:"s" long:"strict" description:"fail if the template refers undefined variables" env:"TEMPLATE_STRICT"`
+	ListVars   bool     `short:"l" long:"list-vars" description:"print variables the template uses instead of rendering"`
+	Partials   []string `short:"p" long:"partials" description:"directory of partials, in addition to partials next to the template" env:"TEMPLATE_PARTIALS_PATH"`
+	BasisSet   string   `short:"b" long:"basisset" description:"basisset.json for basisFunctions helper (basisset.json next to the bin directory by default)" env:"FMOE_BASISSET_PATH"`
+	Scheduler  string   `short:"c" long:"check-script" description:"check directives of the rendered job script, auto detects the scheduler" choice:"auto" choice:"slurm" choice:"pbs" choice:"pjm" choice:"sge" env:"TEMPLATE_CHECK_SCRIPT"`
 }
 
 func mainProcess() error {
@@ -20,6 +26,20 @@ func mainProcess() error {
 	if _, err := flags.ParseArgs(&opts, os.Args); err != nil {
 		return err
 	}
+	stdinData := false
+	for _, path := range opts.DataPaths {
+		if path == "-" {
+			stdinData = true
+		}
+	}
+	if stdinData && opts.InputPath == "" {
+		return errors.New("the template must be given by `-i, --input' to read data from stdin")
+	}
+	data, err := templateData(opts.DataPaths)
+	if err != nil {
+		return err
+	}
+
 	var input *os.File
 	if opts.InputPath == "" {
 		input = os.Stdin
@@ -31,37 +51,73 @@ func mainProcess() error {
 		}
 	}
 
-	var output *os.File
-	if opts.OutputPath == "" {
-		output = os.Stdout
-	} else {
-		if f, err := os.Create(opts.OutputPath); err == nil {
-			output = f
-		} else {
-			return err
-		}
-	}
-
 	b, err := ioutil.ReadAll(input)
 	if err != nil {
 		return err
 	}
-	tpl := string(b)
+	chain, err := templateChain(templateSource{Path: opts.InputPath, Source: string(b)})
+	if err != nil {
+		return err
+	}
+	partials, err := loadPartials(partialDirs(opts.InputPath, opts.Partials))
+	if err != nil {
+		return err
+	}
+
+	basisSetPath := opts.BasisSet
+	if basisSetPath == "" {
+		basisSetPath = defaultBasisSetPath()
+	}
+	templateHelpers := helpers(basisSetPath)
+
+	var variables []Variable
+	if opts.Strict || opts.ListVars {
+		if variables, err = chainVariables(chain, templateHelpers, partials); err != nil {
+			return err
+		}
+		if opts.ListVars {
+			for _, name := range variableNames(variables) {
+				fmt.Println(name)
+			}
+			return nil
+		}
+	}
 
-	rawEnvs := os.Environ()
-	envs := make(map[string]string, len(rawEnvs))
+	if opts.Manifest != "" {
+		if opts.OutputPath == "" {
+			return errors.New("the output path pattern `-o, --output' is required with `-m, --manifest'")
+		}
+		rows, err := loadManifest(opts.Manifest)
+		if err != nil {
+			return err
+		}
+		b, err := newBatch(opts.OutputPath, chain, templateHelpers, partials, variables, opts.Scheduler)
+		if err != nil {
+			return err
+		}
+		return b.run(rows, data)
+	}
 
-	for _, rawEnv := range rawEnvs {
-		if i := strings.IndexRune(rawEnv, '='); i >= 0 {
-			envs[rawEnv[:i]] = rawEnv[i+1:]
+	if opts.Strict {
+		if err := checkVariables(variables, data); err != nil {
+			return err
 		}
 	}
 
-	result, err := raymond.Render(tpl, envs)
+	result, err := renderChain(chain, data, templateHelpers, partials)
+	if err != nil {
+		return err
+	}
+	documents, multi, err := splitOutput(opts.OutputPath, result)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(output, result)
+	if err := checkDocuments(documents, multi, opts.Scheduler); err != nil {
+		return err
+	}
+	if _, err := writeDocuments(documents); err != nil {
+		return err
+	}
 
 	return nil
 }
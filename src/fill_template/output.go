@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// documentSeparator starts an output file of a multi-document template, e.g. "==> {{{BASENAME}}}.sh <=="
+var documentSeparator = regexp.MustCompile(`(?m)^==> (.*?) <==[ \t]*\r?(\n|$)`)
+
+// document is an output file of a rendered template
+type document struct {
+	// Path is empty for stdout
+	Path    string
+	Content string
+}
+
+func (d *document) name() string {
+	if d.Path == "" {
+		return "<stdout>"
+	}
+	return d.Path
+}
+
+// splitOutput splits the rendered template into documents by the separators.
+// The result without separators is a single document written to output,
+// otherwise output is the directory of the documents, whose paths are relative to it.
+// multi reports the result has separators.
+func splitOutput(output string, result string) (documents []document, multi bool, err error) {
+	matches := documentSeparator.FindAllStringSubmatchIndex(result, -1)
+	if len(matches) == 0 {
+		return []document{{Path: output, Content: result}}, false, nil
+	}
+	if strings.TrimSpace(result[:matches[0][0]]) != "" {
+		return nil, true, errors.New("content before the first separator `==> path <=='")
+	}
+
+	dir := output
+	if dir == "" {
+		dir = "."
+	}
+	seen := make(map[string]bool)
+	for i, m := range matches {
+		path := strings.TrimSpace(result[m[2]:m[3]])
+		if path == "" {
+			return nil, true, fmt.Errorf("separator %d has an empty path", i+1)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if seen[path] {
+			return nil, true, fmt.Errorf("%s is written twice", path)
+		}
+		seen[path] = true
+
+		end := len(result)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		documents = append(documents, document{Path: path, Content: result[m[1]:end]})
+	}
+	return documents, true, nil
+}
+
+// checkDocuments checks directives of job scripts in the documents for the scheduler.
+// Documents of a multi-document template are checked only if they have directives, e.g. the ajf is skipped.
+func checkDocuments(documents []document, multi bool, scheduler string) error {
+	if scheduler == "" {
+		return nil
+	}
+	for _, d := range documents {
+		if multi && DetectScheduler(d.Content) == "" {
+			continue
+		}
+		if err := checkRenderedScript(d.name(), d.Content, scheduler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDocuments writes the documents, and returns the written paths
+func writeDocuments(documents []document) ([]string, error) {
+	var written []string
+	for _, d := range documents {
+		if d.Path == "" {
+			fmt.Fprint(os.Stdout, d.Content)
+			continue
+		}
+		if err := ioutil.WriteFile(d.Path, []byte(d.Content), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, d.Path)
+	}
+	return written, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+)
+
+// partialsDir is the directory of partials next to the template
+const partialsDir = "partials"
+
+// maxLayoutDepth limits the chain of base templates, to detect cyclic inheritance
+const maxLayoutDepth = 10
+
+// loadPartials reads files in the directories as partials named by the file name without extension,
+// e.g. partials/site.ajf is included by {{> site}}. Partials of later directories take precedence.
+func loadPartials(dirs []string) (map[string]string, error) {
+	partials := make(map[string]string)
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+			partials[name] = string(b)
+		}
+	}
+	return partials, nil
+}
+
+// partialDirs returns partials directories in the parent directory of the template and next to the template if exist,
+// followed by the given directories. e.g. templates/schedulers/sample_slurm.multi includes templates/partials/site.ajf.
+func partialDirs(templatePath string, dirs []string) []string {
+	var result []string
+	if templatePath != "" {
+		dir := filepath.Dir(templatePath)
+		for _, d := range []string{filepath.Join(dir, "..", partialsDir), filepath.Join(dir, partialsDir)} {
+			if info, err := os.Stat(d); err == nil && info.IsDir() {
+				result = append(result, d)
+			}
+		}
+	}
+	return append(result, dirs...)
+}
+
+// templateSource is a template and its path, which is empty for stdin
+type templateSource struct {
+	Path   string
+	Source string
+}
+
+func (t *templateSource) name() string {
+	if t.Path == "" {
+		return "<stdin>"
+	}
+	return t.Path
+}
+
+// layoutPattern is the base template declaration at the head of a template, e.g. {{!< base.ajf}}
+var layoutPattern = regexp.MustCompile(`^\s*\{\{!<\s*(\S+?)\s*\}\}`)
+
+// layoutOf returns the base template path declared in the template, relative to the template
+func layoutOf(source string) string {
+	if m := layoutPattern.FindStringSubmatch(source); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// templateChain reads base templates of the template recursively.
+// The result starts with the template, followed by its base, base of the base, ...
+func templateChain(template templateSource) ([]templateSource, error) {
+	chain := []templateSource{template}
+	for {
+		current := chain[len(chain)-1]
+		base := layoutOf(current.Source)
+		if base == "" {
+			return chain, nil
+		}
+		if len(chain) > maxLayoutDepth {
+			return nil, fmt.Errorf("%s: too deep or cyclic base templates", template.name())
+		}
+		dir := "."
+		if current.Path != "" {
+			dir = filepath.Dir(current.Path)
+		}
+		path := filepath.Join(dir, base)
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: base template: %w", current.name(), err)
+		}
+		chain = append(chain, templateSource{Path: path, Source: string(b)})
+	}
+}
+
+// layoutHelpers returns block and override helpers sharing the overrides.
+//
+// A base template declares replaceable blocks with default contents:
+//
+//	{{#block "scf"}}&SCF
+//	/{{/block}}
+//
+// and a template starting with {{!< base.ajf}} replaces them:
+//
+//	{{#override "scf"}}&SCF
+//	  MaxItr=100
+//	/{{/override}}
+//
+// Output of the template outside of overrides is discarded. Overrides of the derived templates take precedence.
+func layoutHelpers(overrides map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"block": func(name string, options *raymond.Options) interface{} {
+			if content, ok := overrides[name]; ok {
+				return raymond.SafeString(content)
+			}
+			return options.Fn()
+		},
+		"override": func(name string, options *raymond.Options) interface{} {
+			if _, ok := overrides[name]; !ok {
+				overrides[name] = options.Fn()
+			}
+			return ""
+		},
+	}
+}
+
+// renderChain renders the template with its base templates
+func renderChain(chain []templateSource, data map[string]interface{}, helpers map[string]interface{}, partials map[string]string) (string, error) {
+	overrides := make(map[string]string)
+	var result string
+	for _, t := range chain {
+		template, err := raymond.Parse(t.Source)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.name(), err)
+		}
+		template.RegisterHelpers(helpers)
+		template.RegisterHelpers(layoutHelpers(overrides))
+		template.RegisterPartials(partials)
+		if result, err = template.Exec(data); err != nil {
+			return "", fmt.Errorf("%s: %w", t.name(), err)
+		}
+	}
+	return result, nil
+}
+
+// chainVariables collects variables of the template, its base templates and the partials they include
+func chainVariables(chain []templateSource, helpers map[string]interface{}, partials map[string]string) ([]Variable, error) {
+	names := helperNames(helpers)
+	for name := range layoutHelpers(nil) {
+		names[name] = true
+	}
+	programs := make(map[string]*ast.Program, len(partials))
+	for name, source := range partials {
+		program, err := parser.Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("partial %s: %w", name, err)
+		}
+		programs[name] = program
+	}
+
+	var variables []Variable
+	for _, t := range chain {
+		program, err := parser.Parse(t.Source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name(), err)
+		}
+		variables = append(variables, collectVariables(t.name(), program, names, programs)...)
+	}
+	return variables, nil
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aymerick/raymond"
+)
+
+// resource defaults of job script helpers, overridden by hash arguments, e.g. {{nodes NUM_FRAGS ranks_per_node=8}}
+var resourceDefaults = map[string]float64{
+	// fragments assigned to a MPI rank
+	"frags_per_rank": 4,
+	"ranks_per_node": 4,
+	"cores_per_node": 48,
+	// maximum number of ranks, 0 for unlimited
+	"max_ranks": 0,
+	// rough cost of a basis function divided among ranks
+	"seconds_per_function": 30,
+	// walltime is rounded up to the step, and clamped to [min_walltime, max_walltime]
+	"walltime_step": 600,
+	"min_walltime":  1800,
+	"max_walltime":  24 * 3600,
+}
+
+func resourceParam(helper string, options *raymond.Options, name string) float64 {
+	if v := options.HashProp(name); v != nil {
+		return toNumber(helper, v)
+	}
+	return resourceDefaults[name]
+}
+
+// missing reports the helper parameter is not given, e.g. an undefined or empty variable
+func missing(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// mpiRanks is ceil(frags / frags_per_rank), limited by max_ranks. It is 1 for missing frags.
+func mpiRanks(helper string, frags interface{}, options *raymond.Options) float64 {
+	perRank := resourceParam(helper, options, "frags_per_rank")
+	if perRank <= 0 {
+		panic(&helperError{Helper: helper, Cause: errors.New("frags_per_rank must be positive")})
+	}
+	if missing(frags) {
+		return 1
+	}
+	ranks := math.Max(1, math.Ceil(toNumber(helper, frags)/perRank))
+	if max := resourceParam(helper, options, "max_ranks"); max > 0 {
+		ranks = math.Min(ranks, max)
+	}
+	return ranks
+}
+
+func ranksPerNode(helper string, options *raymond.Options) float64 {
+	perNode := resourceParam(helper, options, "ranks_per_node")
+	if perNode <= 0 {
+		panic(&helperError{Helper: helper, Cause: errors.New("ranks_per_node must be positive")})
+	}
+	return perNode
+}
+
+// mpiRanksHelper returns number of MPI ranks for the fragments.
+//
+//	{{mpiRanks NUM_FRAGS frags_per_rank=4 max_ranks=0}}
+func mpiRanksHelper(frags interface{}, options *raymond.Options) interface{} {
+	return fromNumber(mpiRanks("mpiRanks", frags, options))
+}
+
+// nodesHelper returns number of nodes for the ranks of the fragments.
+//
+//	{{nodes NUM_FRAGS frags_per_rank=4 ranks_per_node=4}}
+func nodesHelper(frags interface{}, options *raymond.Options) interface{} {
+	return fromNumber(math.Ceil(mpiRanks("nodes", frags, options) / ranksPerNode("nodes", options)))
+}
+
+// threadsHelper returns OpenMP threads of a rank.
+//
+//	{{threads cores_per_node=48 ranks_per_node=4}}
+func threadsHelper(options *raymond.Options) interface{} {
+	return fromNumber(math.Max(1, math.Floor(resourceParam("threads", options, "cores_per_node")/ranksPerNode("threads", options))))
+}
+
+// walltimeHelper estimates walltime as HH:MM:SS from the total of basis functions divided among the ranks.
+// It is max_walltime for missing functions, e.g. basis sets without the number of functions.
+//
+//	{{walltime NUM_BASIS_FUNCTIONS NUM_FRAGS seconds_per_function=30}}
+func walltimeHelper(functions interface{}, frags interface{}, options *raymond.Options) interface{} {
+	var seconds float64
+	if missing(functions) {
+		seconds = resourceParam("walltime", options, "max_walltime")
+	} else {
+		ranks := mpiRanks("walltime", frags, options)
+		seconds = toNumber("walltime", functions) * resourceParam("walltime", options, "seconds_per_function") / ranks
+	}
+	if step := resourceParam("walltime", options, "walltime_step"); step > 0 {
+		seconds = math.Ceil(seconds/step) * step
+	}
+	seconds = math.Max(seconds, resourceParam("walltime", options, "min_walltime"))
+	seconds = math.Min(seconds, resourceParam("walltime", options, "max_walltime"))
+	s := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s%3600/60, s%60)
+}
+
+// schedulerHelpers returns helpers deriving resources of job scripts
+func schedulerHelpers() map[string]interface{} {
+	return map[string]interface{}{
+		"mpiRanks": mpiRanksHelper,
+		"nodes":    nodesHelper,
+		"threads":  threadsHelper,
+		"walltime": walltimeHelper,
+	}
+}
+
+// ScriptDiagnostic is a problem of a directive of a job script
+type ScriptDiagnostic struct {
+	Line    int
+	Message string
+	// Warning does not fail the check, e.g. an option unknown to the checker
+	Warning bool
+}
+
+// schedulers are directive prefixes of the schedulers
+var schedulers = []struct {
+	Name   string
+	Prefix string
+}{
+	{"slurm", "#SBATCH"},
+	{"pbs", "#PBS"},
+	{"pjm", "#PJM"},
+	{"sge", "#$"},
+}
+
+// optionSpec is number of arguments and the checker of the last argument
+type optionSpec struct {
+	args  int
+	check func(string) error
+}
+
+var (
+	timePattern      = regexp.MustCompile(`^\d+:\d{1,2}:\d{1,2}$`)
+	slurmTimePattern = regexp.MustCompile(`^(\d+-\d+(:\d+(:\d+)?)?|\d+(:\d+(:\d+)?)?)$`)
+	memoryPattern    = regexp.MustCompile(`^\d+[KMGT]?B?$`)
+	namePattern      = regexp.MustCompile(`^[A-Za-z][^\s/]*$`)
+)
+
+func checkPositiveInt(s string) error {
+	if n, err := strconv.Atoi(s); err != nil || n <= 0 {
+		return fmt.Errorf("%q is not a positive integer", s)
+	}
+	return nil
+}
+
+// checkTime accepts HH:MM:SS or seconds
+func checkTime(s string) error {
+	if timePattern.MatchString(s) {
+		return nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n > 0 {
+		return nil
+	}
+	return fmt.Errorf("%q is not a time, HH:MM:SS", s)
+}
+
+func checkSlurmTime(s string) error {
+	if !slurmTimePattern.MatchString(s) {
+		return fmt.Errorf("%q is not a time, [D-]HH:MM:SS", s)
+	}
+	return nil
+}
+
+func checkMemory(s string) error {
+	if !memoryPattern.MatchString(strings.ToUpper(s)) {
+		return fmt.Errorf("%q is not a memory size", s)
+	}
+	return nil
+}
+
+func checkName(s string) error {
+	if !namePattern.MatchString(s) {
+		return fmt.Errorf("%q is not a job name starting with a letter", s)
+	}
+	return nil
+}
+
+func checkChoice(choices ...string) func(string) error {
+	return func(s string) error {
+		for _, c := range choices {
+			if s == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, strings.Join(choices, ", "))
+	}
+}
+
+func checkSlurmNodes(s string) error {
+	for _, n := range strings.SplitN(s, "-", 2) {
+		if err := checkPositiveInt(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkResources checks comma separated key=value list, e.g. walltime=01:00:00,ncpus=4.
+// Values of the time keys are checked, and the others are chunks of key=value separated by colons if chunks,
+// e.g. select=2:ncpus=48 of PBS.
+func checkResources(chunks bool, times ...string) func(string) error {
+	return func(s string) error {
+		for _, item := range strings.Split(s, ",") {
+			i := strings.IndexRune(item, '=')
+			if i <= 0 || i == len(item)-1 {
+				return fmt.Errorf("%q is not key=value", item)
+			}
+			key, value := item[:i], item[i+1:]
+			isTime := false
+			for _, t := range times {
+				if key == t {
+					isTime = true
+				}
+			}
+			if isTime {
+				if err := checkTime(value); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				continue
+			}
+			if !chunks {
+				continue
+			}
+			parts := strings.Split(value, ":")
+			if parts[0] == "" {
+				return fmt.Errorf("%q is not key=value", item)
+			}
+			for _, kv := range parts[1:] {
+				if j := strings.IndexRune(kv, '='); j <= 0 || j == len(kv)-1 {
+					return fmt.Errorf("%q is not key=value", kv)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+var schedulerOptions = map[string]map[string]optionSpec{
+	"slurm": {
+		"-J": {1, nil}, "--job-name": {1, nil},
+		"-N": {1, checkSlurmNodes}, "--nodes": {1, checkSlurmNodes},
+		"-n": {1, checkPositiveInt}, "--ntasks": {1, checkPositiveInt},
+		"--ntasks-per-node": {1, checkPositiveInt},
+		"-c":                {1, checkPositiveInt}, "--cpus-per-task": {1, checkPositiveInt},
+		"-t": {1, checkSlurmTime}, "--time": {1, checkSlurmTime},
+		"-p": {1, nil}, "--partition": {1, nil},
+		"-A": {1, nil}, "--account": {1, nil},
+		"-q": {1, nil}, "--qos": {1, nil},
+		"-o": {1, nil}, "--output": {1, nil},
+		"-e": {1, nil}, "--error": {1, nil},
+		"-D": {1, nil}, "--chdir": {1, nil},
+		"-C": {1, nil}, "--constraint": {1, nil},
+		"-a": {1, nil}, "--array": {1, nil},
+		"-d": {1, nil}, "--dependency": {1, nil},
+		"--mem": {1, checkMemory}, "--mem-per-cpu": {1, checkMemory},
+		"--gres": {1, nil}, "--export": {1, nil}, "--hint": {1, nil}, "--signal": {1, nil},
+		"--mail-type": {1, nil}, "--mail-user": {1, nil},
+		"--threads-per-core": {1, checkPositiveInt},
+		"--exclusive":        {0, nil}, "--requeue": {0, nil}, "--no-requeue": {0, nil},
+	},
+	"pbs": {
+		"-N": {1, checkName},
+		"-l": {1, checkResources(true, "walltime", "cput")},
+		"-q": {1, nil}, "-A": {1, nil}, "-W": {1, nil}, "-S": {1, nil}, "-J": {1, nil},
+		"-o": {1, nil}, "-e": {1, nil}, "-m": {1, nil}, "-M": {1, nil}, "-v": {1, nil}, "-k": {1, nil},
+		"-j": {1, checkChoice("oe", "eo", "n")},
+		"-r": {1, checkChoice("y", "n")},
+		"-p": {1, nil},
+		"-V": {0, nil},
+	},
+	"pjm": {
+		"-L":    {1, checkResources(false, "elapse")},
+		"--mpi": {1, checkResources(false)},
+		"-g":    {1, nil}, "-x": {1, nil}, "-o": {1, nil}, "-e": {1, nil}, "-m": {1, nil},
+		"-N": {1, checkName}, "--name": {1, checkName},
+		"--mail-list": {1, nil}, "--sparam": {1, nil}, "--llio": {1, nil}, "-z": {1, nil},
+		"-j": {0, nil}, "-S": {0, nil}, "-s": {0, nil}, "-X": {0, nil},
+		"--step": {0, nil}, "--no-check-directory": {0, nil},
+	},
+	"sge": {
+		"-N":  {1, checkName},
+		"-pe": {2, checkSlurmNodes},
+		"-l":  {1, checkResources(false, "h_rt", "s_rt")},
+		"-j":  {1, checkChoice("y", "n")},
+		"-q":  {1, nil}, "-o": {1, nil}, "-e": {1, nil}, "-S": {1, nil}, "-m": {1, nil}, "-M": {1, nil},
+		"-wd": {1, nil}, "-t": {1, nil}, "-hold_jid": {1, nil}, "-b": {1, nil}, "-P": {1, nil},
+		"-A": {1, nil}, "-v": {1, nil}, "-R": {1, nil},
+		"-cwd": {0, nil}, "-V": {0, nil}, "-notify": {0, nil},
+	},
+}
+
+// DetectScheduler returns the scheduler of directives in the script, or empty if no directive
+func DetectScheduler(script string) string {
+	for _, line := range strings.Split(script, "\n") {
+		for _, s := range schedulers {
+			if strings.HasPrefix(line, s.Prefix) {
+				return s.Name
+			}
+		}
+	}
+	return ""
+}
+
+// splitArgs splits directive arguments by spaces, keeping quoted arguments
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// CheckScript checks syntax of the directive block of a job script for the scheduler.
+// Directives must precede the first command, and options must have non-empty valid values.
+func CheckScript(script string, scheduler string) []ScriptDiagnostic {
+	var prefix string
+	for _, s := range schedulers {
+		if s.Name == scheduler {
+			prefix = s.Prefix
+		}
+	}
+	specs := schedulerOptions[scheduler]
+
+	var diagnostics []ScriptDiagnostic
+	report := func(line int, warning bool, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, ScriptDiagnostic{Line: line, Message: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	seenDirective := false
+	afterCommand := false
+	for i, text := range strings.Split(script, "\n") {
+		line := i + 1
+		trimmed := strings.TrimSpace(text)
+		if !strings.HasPrefix(text, prefix) {
+			for _, s := range schedulers {
+				if s.Name != scheduler && strings.HasPrefix(text, s.Prefix+" ") {
+					report(line, false, "%s directive in a %s script", s.Prefix, scheduler)
+				}
+			}
+			if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+				afterCommand = true
+			}
+			continue
+		}
+		seenDirective = true
+		// SGE reads directives in the whole script, the others stop at the first command
+		if afterCommand && scheduler != "sge" {
+			report(line, false, "%s directive after the first command is ignored", prefix)
+		}
+
+		args, err := splitArgs(text[len(prefix):])
+		if err != nil {
+			report(line, false, "%v", err)
+			continue
+		}
+		if len(args) == 0 {
+			report(line, false, "empty %s directive", prefix)
+			continue
+		}
+		for j := 0; j < len(args); j++ {
+			option := args[j]
+			var values []string
+			if strings.HasPrefix(option, "--") {
+				if k := strings.IndexRune(option, '='); k >= 0 {
+					option, values = option[:k], []string{option[k+1:]}
+				}
+			}
+			if !strings.HasPrefix(option, "-") {
+				report(line, false, "%q is not an option", option)
+				break
+			}
+			spec, ok := specs[option]
+			if !ok {
+				report(line, true, "option %s is unknown to the checker", option)
+				break
+			}
+			for len(values) < spec.args && j+1 < len(args) {
+				j++
+				values = append(values, args[j])
+			}
+			if len(values) < spec.args {
+				report(line, false, "option %s requires %d argument(s)", option, spec.args)
+				break
+			}
+			if spec.args == 0 && len(values) > 0 {
+				report(line, false, "option %s takes no argument", option)
+				continue
+			}
+			empty := false
+			for _, v := range values {
+				if strings.TrimSpace(v) == "" {
+					report(line, false, "empty value of option %s", option)
+					empty = true
+				}
+			}
+			if !empty && spec.check != nil {
+				if err := spec.check(values[len(values)-1]); err != nil {
+					report(line, false, "option %s: %v", option, err)
+				}
+			}
+		}
+	}
+	if !seenDirective {
+		report(0, false, "no %s directive", prefix)
+	}
+	return diagnostics
+}
+
+// ScriptError is errors of directives of a job script
+type ScriptError struct {
+	Name        string
+	Diagnostics []ScriptDiagnostic
+}
+
+func (err *ScriptError) Error() string {
+	var lines []string
+	for _, d := range err.Diagnostics {
+		if !d.Warning {
+			lines = append(lines, fmt.Sprintf("%s:%d: %s", err.Name, d.Line, d.Message))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkRenderedScript checks the rendered job script named name for the scheduler, or the detected one for "auto".
+// Warnings are printed to stderr.
+func checkRenderedScript(name string, script string, scheduler string) error {
+	if scheduler == "auto" {
+		if scheduler = DetectScheduler(script); scheduler == "" {
+			return fmt.Errorf("%s: no scheduler directive", name)
+		}
+	}
+	failed := false
+	diagnostics := CheckScript(script, scheduler)
+	for _, d := range diagnostics {
+		if d.Warning {
+			fmt.Fprintf(os.Stderr, "%s:%d: warning: %s\n", name, d.Line, d.Message)
+		} else {
+			failed = true
+		}
+	}
+	if failed {
+		return &ScriptError{Name: name, Diagnostics: diagnostics}
+	}
+	return nil
+}
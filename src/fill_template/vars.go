@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond/ast"
+)
+
+// Variable is a reference to template data
+type Variable struct {
+	// Path is dot separated path from the root of template data, e.g. "BASENAME" or "ligand.charge"
+	Path string
+	// Template is the template or partial name where the variable is referred
+	Template string
+	Line     int
+}
+
+// builtinHelpers are helpers of raymond
+var builtinHelpers = map[string]bool{
+	"if": true, "unless": true, "each": true, "with": true, "lookup": true, "log": true, "equal": true,
+}
+
+// scopeHelpers are block helpers which change the context in their body
+var scopeHelpers = map[string]bool{
+	"each": true, "with": true,
+}
+
+// variableCollector collects variables referring the root context.
+// Paths in the body of each and with refer their own context, so only the paths climbing to the root by "../" are collected.
+// Partials are followed, and variables in a partial with a context parameter or a hash are handled as in the body of with.
+// @root paths refer the root context at any depth.
+type variableCollector struct {
+	variables []Variable
+	depth     int
+	helpers   map[string]bool
+	partials  map[string]*ast.Program
+	template  string
+	visiting  map[string]bool
+}
+
+// collectVariables returns variables in the template named name in order of appearance
+func collectVariables(name string, program *ast.Program, helpers map[string]bool, partials map[string]*ast.Program) []Variable {
+	c := variableCollector{helpers: helpers, partials: partials, template: name, visiting: make(map[string]bool)}
+	program.Accept(&c)
+	return c.variables
+}
+
+func (c *variableCollector) isHelper(name string) bool {
+	return builtinHelpers[name] || c.helpers[name]
+}
+
+func (c *variableCollector) VisitProgram(node *ast.Program) interface{} {
+	for _, n := range node.Body {
+		n.Accept(c)
+	}
+	return nil
+}
+
+func (c *variableCollector) VisitMustache(node *ast.MustacheStatement) interface{} {
+	node.Expression.Accept(c)
+	return nil
+}
+
+func (c *variableCollector) VisitBlock(node *ast.BlockStatement) interface{} {
+	node.Expression.Accept(c)
+	if scopeHelpers[node.Expression.HelperName()] {
+		c.depth++
+		defer func() { c.depth-- }()
+	}
+	if node.Program != nil {
+		node.Program.Accept(c)
+	}
+	if node.Inverse != nil {
+		node.Inverse.Accept(c)
+	}
+	return nil
+}
+
+func (c *variableCollector) VisitPartial(node *ast.PartialStatement) interface{} {
+	for _, n := range node.Params {
+		n.Accept(c)
+	}
+	if node.Hash != nil {
+		node.Hash.Accept(c)
+	}
+
+	var name string
+	switch n := node.Name.(type) {
+	case *ast.PathExpression:
+		name = n.Original
+	case *ast.StringLiteral:
+		name = n.Value
+	}
+	program, ok := c.partials[name]
+	if !ok || c.visiting[name] {
+		return nil
+	}
+	c.visiting[name] = true
+	template := c.template
+	c.template = name
+	// the context parameter or the hash is the context of the partial
+	scoped := len(node.Params) > 0 || node.Hash != nil
+	if scoped {
+		c.depth++
+	}
+	program.Accept(c)
+	if scoped {
+		c.depth--
+	}
+	c.template = template
+	c.visiting[name] = false
+	return nil
+}
+
+func (c *variableCollector) VisitContent(node *ast.ContentStatement) interface{} { return nil }
+func (c *variableCollector) VisitComment(node *ast.CommentStatement) interface{} { return nil }
+
+func (c *variableCollector) VisitExpression(node *ast.Expression) interface{} {
+	// helper name itself is not a variable
+	if name := node.HelperName(); name == "" || !c.isHelper(name) {
+		node.Path.Accept(c)
+	}
+	for _, n := range node.Params {
+		n.Accept(c)
+	}
+	if node.Hash != nil {
+		node.Hash.Accept(c)
+	}
+	return nil
+}
+
+func (c *variableCollector) VisitSubExpression(node *ast.SubExpression) interface{} {
+	node.Expression.Accept(c)
+	return nil
+}
+
+func (c *variableCollector) VisitPath(node *ast.PathExpression) interface{} {
+	if node.IsDataRoot() && len(node.Parts) > 1 {
+		c.variables = append(c.variables, Variable{Path: strings.Join(node.Parts[1:], "."), Template: c.template, Line: node.Line})
+		return nil
+	}
+	if node.Data || len(node.Parts) == 0 || node.Depth < c.depth {
+		return nil
+	}
+	c.variables = append(c.variables, Variable{Path: strings.Join(node.Parts, "."), Template: c.template, Line: node.Line})
+	return nil
+}
+
+func (c *variableCollector) VisitString(node *ast.StringLiteral) interface{}   { return nil }
+func (c *variableCollector) VisitBoolean(node *ast.BooleanLiteral) interface{} { return nil }
+func (c *variableCollector) VisitNumber(node *ast.NumberLiteral) interface{}   { return nil }
+
+func (c *variableCollector) VisitHash(node *ast.Hash) interface{} {
+	for _, p := range node.Pairs {
+		p.Accept(c)
+	}
+	return nil
+}
+
+func (c *variableCollector) VisitHashPair(node *ast.HashPair) interface{} {
+	node.Val.Accept(c)
+	return nil
+}
+
+// variableNames returns sorted unique paths of the variables
+func variableNames(variables []Variable) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, v := range variables {
+		if !seen[v.Path] {
+			seen[v.Path] = true
+			names = append(names, v.Path)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UndefinedVariables error
+type UndefinedVariables struct {
+	Variables []Variable
+}
+
+func (err *UndefinedVariables) Error() string {
+	lines := make([]string, len(err.Variables))
+	for i, v := range err.Variables {
+		lines[i] = fmt.Sprintf("%s:%d: undefined variable %s", v.Template, v.Line, v.Path)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// checkVariables checks all the variables are defined in the data
+func checkVariables(variables []Variable, data map[string]interface{}) error {
+	var undefined []Variable
+	for _, v := range variables {
+		if !defined(data, strings.Split(v.Path, ".")) {
+			undefined = append(undefined, v)
+		}
+	}
+	if len(undefined) > 0 {
+		return &UndefinedVariables{Variables: undefined}
+	}
+	return nil
+}
+
+func defined(data map[string]interface{}, parts []string) bool {
+	value, ok := data[parts[0]]
+	if !ok || value == nil {
+		return false
+	}
+	if len(parts) == 1 {
+		return true
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return defined(v, parts[1:])
+	case []interface{}:
+		// e.g. list.length or list.[0] is not checked
+		return true
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+#!/bin/bash
+#PBS -N {{{BASENAME}}}
+#PBS -l select={{nodes NUM_FRAGS frags_per_rank=4 ranks_per_node=4}}:ncpus=48:mpiprocs=4:ompthreads={{threads cores_per_node=48 ranks_per_node=4}}
+#PBS -l walltime={{walltime NUM_BASIS_FUNCTIONS NUM_FRAGS frags_per_rank=4}}
+#PBS -j oe
+
+export OMP_NUM_THREADS={{threads cores_per_node=48 ranks_per_node=4}}
+ABINITMP=${ABINITMP:-abinitmp}
+
+cd "$PBS_O_WORKDIR"
+mpirun -np {{mpiRanks NUM_FRAGS frags_per_rank=4}} $ABINITMP < {{{BASENAME}}}.ajf > {{{BASENAME}}}.log
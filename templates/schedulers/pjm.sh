@@ -0,0 +1,13 @@
+#!/bin/bash
+#PJM -L "node={{nodes NUM_FRAGS frags_per_rank=4 ranks_per_node=4}}"
+#PJM -L "rscgrp=small"
+#PJM -L "elapse={{walltime NUM_BASIS_FUNCTIONS NUM_FRAGS frags_per_rank=4}}"
+#PJM --mpi "proc={{mpiRanks NUM_FRAGS frags_per_rank=4}},max-proc-per-node=4"
+#PJM -N {{{BASENAME}}}
+#PJM -j
+#PJM -S
+
+export OMP_NUM_THREADS={{threads cores_per_node=48 ranks_per_node=4}}
+ABINITMP=${ABINITMP:-abinitmp}
+
+mpiexec -stdin {{{BASENAME}}}.ajf $ABINITMP > {{{BASENAME}}}.log
@@ -0,0 +1,12 @@
+#!/bin/bash
+#$ -N {{{BASENAME}}}
+#$ -pe mpi {{mpiRanks NUM_FRAGS frags_per_rank=4}}
+#$ -l h_rt={{walltime NUM_BASIS_FUNCTIONS NUM_FRAGS frags_per_rank=4}}
+#$ -cwd
+#$ -V
+#$ -j y
+
+export OMP_NUM_THREADS=1
+ABINITMP=${ABINITMP:-abinitmp}
+
+mpirun -np $NSLOTS $ABINITMP < {{{BASENAME}}}.ajf > {{{BASENAME}}}.log
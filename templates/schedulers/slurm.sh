@@ -0,0 +1,14 @@
+#!/bin/bash
+#SBATCH --job-name={{{BASENAME}}}
+#SBATCH --nodes={{nodes NUM_FRAGS frags_per_rank=4 ranks_per_node=4}}
+#SBATCH --ntasks={{mpiRanks NUM_FRAGS frags_per_rank=4}}
+#SBATCH --ntasks-per-node=4
+#SBATCH --cpus-per-task={{threads cores_per_node=48 ranks_per_node=4}}
+#SBATCH --time={{walltime NUM_BASIS_FUNCTIONS NUM_FRAGS frags_per_rank=4}}
+#SBATCH --output={{{BASENAME}}}.%j.out
+
+export OMP_NUM_THREADS=$SLURM_CPUS_PER_TASK
+ABINITMP=${ABINITMP:-abinitmp}
+
+cd "$SLURM_SUBMIT_DIR"
+srun $ABINITMP < {{{BASENAME}}}.ajf > {{{BASENAME}}}.log
@@ -0,0 +1,29 @@
+&FRAGMENT
+      23      12      12      12      23      12      12      12
+       1      -1       0      -1       1      -1       0      -1
+       0       1       1       1       0       1       1       1
+       1       2       3       4       5       6       7       8       9      10
+      23      24      25      26      27      28      29      30      31      32
+      33      34      35
+      11      12      13      14      15      16      17      18      19      20
+      21      22
+      36      37      38      39      40      53      54      55      56      57
+      58      59
+      41      42      43      44      45      46      47      48      49      50
+      51      52
+      60      61      62      63      64      65      66      67      68      69
+      82      83      84      85      86      87      88      89      90      91
+      92      93      94
+      70      71      72      73      74      75      76      77      78      79
+      80      81
+      95      96      97      98      99     112     113     114     115     116
+     117     118
+     100     101     102     103     104     105     106     107     108     109
+     110     111
+       9      11
+      33      36
+      39      41
+      68      70
+      92      95
+      98     100
+/